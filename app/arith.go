@@ -0,0 +1,523 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxArithDepth bounds the recursion through variables whose values are
+// themselves expressions
+const maxArithDepth = 1024
+
+// arithToken is a token of an arithmetic expression
+type arithToken struct {
+	kind byte // 'n' number, 'v' variable, 'o' operator, 0 end of input
+	text string
+	pos  int
+}
+
+// arithOperators lists the arithmetic operators, longest first
+var arithOperators = []string{
+	"<<=", ">>=",
+	"**", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||", "++", "--",
+	"+=", "-=", "*=", "/=", "%=", "&=", "^=", "|=",
+	"+", "-", "*", "/", "%", "<", ">", "&", "|", "^", "!", "~", "?", ":", "=", ",", "(", ")",
+}
+
+// arithLevels lists the left-associative binary operators from the lowest
+// precedence to the highest
+var arithLevels = [][]string{
+	{"|"}, {"^"}, {"&"}, {"==", "!="}, {"<=", ">=", "<", ">"}, {"<<", ">>"}, {"+", "-"}, {"*", "/", "%"},
+}
+
+var arithAssignOps = []string{"=", "*=", "/=", "%=", "+=", "-=", "<<=", ">>=", "&=", "^=", "|="}
+
+// arithmetic evaluates one expression by recursive descent. skip is raised
+// while evaluating an operand of &&, || or ?: that is not taken, so that it
+// neither assigns nor fails on division by zero.
+type arithmetic struct {
+	s      *Shell
+	expr   string
+	tokens []arithToken
+	i      int
+	skip   int
+	depth  int
+}
+
+// evalArith evaluates a shell arithmetic expression, as used by $((...)),
+// let, ((...)), integer variables and array subscripts. An empty expression
+// is 0.
+func (s *Shell) evalArith(expr string) (int, error) {
+	return s.evalArithDepth(expr, 0)
+}
+
+func (s *Shell) evalArithDepth(expr string, depth int) (int, error) {
+	if depth > maxArithDepth {
+		return 0, fmt.Errorf("%s: expression recursion level exceeded", strings.TrimSpace(expr))
+	}
+	tokens, err := lexArith(expr)
+	if err != nil {
+		return 0, err
+	}
+
+	a := &arithmetic{s: s, expr: expr, tokens: tokens, depth: depth}
+	if a.peek().kind == 0 {
+		return 0, nil
+	}
+	n, err := a.comma()
+	if err == nil && a.peek().kind != 0 {
+		err = a.errorAt(a.peek(), "syntax error in expression")
+	}
+	return n, err
+}
+
+// arithValue expands the parameters and command substitutions in the
+// expression of $((...)) or ((...)) and evaluates it
+func (s *Shell) arithValue(expr string) (int, error) {
+	frags, err := s.expandFragments(expr, true, false)
+	if err != nil {
+		return 0, err
+	}
+	return s.evalArith(joinFragments(frags, false))
+}
+
+func lexArith(expr string) ([]arithToken, error) {
+	var tokens []arithToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+			continue
+		case isDigit(c):
+			for i < len(expr) && (isNameChar(expr[i]) || expr[i] == '#' || expr[i] == '@') {
+				i++
+			}
+			tokens = append(tokens, arithToken{kind: 'n', text: expr[start:i], pos: start})
+		case isNameChar(c):
+			for i < len(expr) && isNameChar(expr[i]) {
+				i++
+			}
+			if i < len(expr) && expr[i] == '[' {
+				end := matchingBracket(expr, i)
+				if end < 0 {
+					return nil, fmt.Errorf("%s: bad array subscript", expr[start:])
+				}
+				i = end + 1
+			}
+			tokens = append(tokens, arithToken{kind: 'v', text: expr[start:i], pos: start})
+		default:
+			op := ""
+			for _, o := range arithOperators {
+				if strings.HasPrefix(expr[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("%s: syntax error: invalid arithmetic operator (error token is \"%s\")",
+					strings.TrimSpace(expr), expr[i:])
+			}
+			i += len(op)
+			tokens = append(tokens, arithToken{kind: 'o', text: op, pos: start})
+		}
+	}
+	return append(tokens, arithToken{pos: len(expr)}), nil
+}
+
+// matchingBracket returns the index of the ']' closing the '[' at s[open],
+// or -1
+func matchingBracket(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func (a *arithmetic) peek() arithToken {
+	return a.tokens[a.i]
+}
+
+func (a *arithmetic) next() arithToken {
+	t := a.tokens[a.i]
+	if t.kind != 0 {
+		a.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the operator op
+func (a *arithmetic) accept(op string) bool {
+	if t := a.peek(); t.kind == 'o' && t.text == op {
+		a.i++
+		return true
+	}
+	return false
+}
+
+func (a *arithmetic) errorAt(t arithToken, msg string) error {
+	expr := strings.TrimSpace(a.expr)
+	if t.kind == 0 {
+		return fmt.Errorf("%s: %s", expr, msg)
+	}
+	return fmt.Errorf("%s: %s (error token is \"%s\")", expr, msg, a.expr[t.pos:])
+}
+
+// branch evaluates an operand, with side effects disabled unless it is taken
+func (a *arithmetic) branch(taken bool, eval func() (int, error)) (int, error) {
+	if !taken {
+		a.skip++
+		defer func() { a.skip-- }()
+	}
+	return eval()
+}
+
+func (a *arithmetic) comma() (int, error) {
+	n, err := a.assign()
+	for err == nil && a.accept(",") {
+		n, err = a.assign()
+	}
+	return n, err
+}
+
+func (a *arithmetic) assign() (int, error) {
+	v, op := a.peek(), a.tokens[min(a.i+1, len(a.tokens)-1)]
+	if v.kind != 'v' || op.kind != 'o' || !slices.Contains(arithAssignOps, op.text) {
+		return a.ternary()
+	}
+
+	a.i += 2
+	n, err := a.assign()
+	if err != nil {
+		return 0, err
+	}
+	if op.text != "=" {
+		old, err := a.variable(v)
+		if err != nil {
+			return 0, err
+		}
+		binary := op
+		binary.text = strings.TrimSuffix(op.text, "=")
+		if n, err = a.apply(binary, old, n); err != nil {
+			return 0, err
+		}
+	}
+	return n, a.store(v, n)
+}
+
+func (a *arithmetic) ternary() (int, error) {
+	cond, err := a.logicalOr()
+	if err != nil || !a.accept("?") {
+		return cond, err
+	}
+	then, err := a.branch(cond != 0, a.comma)
+	if err != nil {
+		return 0, err
+	}
+	if !a.accept(":") {
+		return 0, a.errorAt(a.peek(), "`:' expected for conditional expression")
+	}
+	otherwise, err := a.branch(cond == 0, a.ternary)
+	if err != nil {
+		return 0, err
+	}
+	if cond != 0 {
+		return then, nil
+	}
+	return otherwise, nil
+}
+
+func (a *arithmetic) logicalOr() (int, error) {
+	n, err := a.logicalAnd()
+	for err == nil && a.accept("||") {
+		var rhs int
+		rhs, err = a.branch(n == 0, a.logicalAnd)
+		n = boolInt(n != 0 || rhs != 0)
+	}
+	return n, err
+}
+
+func (a *arithmetic) logicalAnd() (int, error) {
+	n, err := a.binary(0)
+	for err == nil && a.accept("&&") {
+		var rhs int
+		rhs, err = a.branch(n != 0, func() (int, error) { return a.binary(0) })
+		n = boolInt(n != 0 && rhs != 0)
+	}
+	return n, err
+}
+
+// binary parses the operators of arithLevels[level] and above
+func (a *arithmetic) binary(level int) (int, error) {
+	if level == len(arithLevels) {
+		return a.power()
+	}
+	n, err := a.binary(level + 1)
+	for err == nil {
+		op := a.peek()
+		if op.kind != 'o' || !slices.Contains(arithLevels[level], op.text) {
+			break
+		}
+		a.i++
+		var rhs int
+		if rhs, err = a.binary(level + 1); err == nil {
+			n, err = a.apply(op, n, rhs)
+		}
+	}
+	return n, err
+}
+
+// power parses the right-associative "**", which binds looser than the
+// unary operators
+func (a *arithmetic) power() (int, error) {
+	base, err := a.unary()
+	if err != nil {
+		return 0, err
+	}
+	op := a.peek()
+	if !a.accept("**") {
+		return base, nil
+	}
+	exp, err := a.power()
+	if err != nil {
+		return 0, err
+	}
+	return a.apply(op, base, exp)
+}
+
+func (a *arithmetic) unary() (int, error) {
+	op := a.peek()
+	if op.kind != 'o' {
+		return a.postfix()
+	}
+
+	switch op.text {
+	case "++", "--":
+		if v := a.tokens[a.i+1]; v.kind == 'v' {
+			a.i += 2
+			old, err := a.variable(v)
+			if err != nil {
+				return 0, err
+			}
+			n := old + 1
+			if op.text == "--" {
+				n = old - 1
+			}
+			return n, a.store(v, n)
+		}
+		// Without a variable these are two signs: --5 is 5
+		a.i++
+		return a.unary()
+	case "+", "-", "!", "~":
+		a.i++
+		n, err := a.unary()
+		switch op.text {
+		case "-":
+			n = -n
+		case "!":
+			n = boolInt(n == 0)
+		case "~":
+			n = ^n
+		}
+		return n, err
+	}
+	return a.postfix()
+}
+
+func (a *arithmetic) postfix() (int, error) {
+	t := a.next()
+	switch {
+	case t.kind == 'n':
+		return a.number(t)
+	case t.kind == 'v':
+		op := a.peek()
+		if op.kind != 'o' || (op.text != "++" && op.text != "--") {
+			return a.variable(t)
+		}
+		a.i++
+		old, err := a.variable(t)
+		if err != nil {
+			return 0, err
+		}
+		n := old + 1
+		if op.text == "--" {
+			n = old - 1
+		}
+		return old, a.store(t, n)
+	case t.kind == 'o' && t.text == "(":
+		n, err := a.comma()
+		if err != nil {
+			return 0, err
+		}
+		if !a.accept(")") {
+			return 0, a.errorAt(a.peek(), "missing `)'")
+		}
+		return n, nil
+	}
+	return 0, a.errorAt(t, "syntax error: operand expected")
+}
+
+// apply performs the binary operator op
+func (a *arithmetic) apply(op arithToken, x, y int) (int, error) {
+	switch op.text {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/", "%":
+		if y == 0 {
+			if a.skip > 0 {
+				return 0, nil
+			}
+			return 0, a.errorAt(a.tokens[a.i-1], "division by 0")
+		}
+		if x == math.MinInt && y == -1 {
+			if op.text == "/" {
+				return x, nil
+			}
+			return 0, nil
+		}
+		if op.text == "/" {
+			return x / y, nil
+		}
+		return x % y, nil
+	case "**":
+		if y < 0 {
+			return 0, a.errorAt(op, "exponent less than 0")
+		}
+		// Exponentiation by squaring, wrapping like the other operators
+		n := 1
+		for ; y > 0; y >>= 1 {
+			if y&1 == 1 {
+				n *= x
+			}
+			x *= x
+		}
+		return n, nil
+	case "<<":
+		return x << (uint(y) & 63), nil
+	case ">>":
+		return x >> (uint(y) & 63), nil
+	case "<":
+		return boolInt(x < y), nil
+	case ">":
+		return boolInt(x > y), nil
+	case "<=":
+		return boolInt(x <= y), nil
+	case ">=":
+		return boolInt(x >= y), nil
+	case "==":
+		return boolInt(x == y), nil
+	case "!=":
+		return boolInt(x != y), nil
+	case "&":
+		return x & y, nil
+	case "^":
+		return x ^ y, nil
+	case "|":
+		return x | y, nil
+	}
+	return 0, a.errorAt(op, "syntax error in expression")
+}
+
+// number converts a decimal, octal (0755), hexadecimal (0xff) or based
+// (base#digits, base 2 to 64) constant
+func (a *arithmetic) number(t arithToken) (int, error) {
+	text, base := t.text, 10
+	switch {
+	case strings.Contains(text, "#"):
+		prefix, digits, _ := strings.Cut(text, "#")
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n < 2 || n > 64 {
+			return 0, a.errorAt(t, "invalid arithmetic base")
+		}
+		text, base = digits, n
+	case strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X"):
+		text, base = text[2:], 16
+	case len(text) > 1 && text[0] == '0':
+		text, base = text[1:], 8
+	}
+	if text == "" {
+		return 0, a.errorAt(t, "invalid number")
+	}
+
+	n := 0
+	for i := 0; i < len(text); i++ {
+		d := digitValue(text[i], base)
+		if d < 0 {
+			return 0, a.errorAt(t, "invalid number")
+		}
+		if d >= base {
+			return 0, a.errorAt(t, "value too great for base")
+		}
+		n = n*base + d
+	}
+	return n, nil
+}
+
+// digitValue returns the value of c as a digit. Up to base 36 letters are
+// case-insensitive; above it lowercase, uppercase, '@' and '_' follow 0-9.
+func digitValue(c byte, base int) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'z':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'Z' && base <= 36:
+		return int(c-'A') + 10
+	case c >= 'A' && c <= 'Z':
+		return int(c-'A') + 36
+	case c == '@':
+		return 62
+	case c == '_':
+		return 63
+	}
+	return -1
+}
+
+// variable returns the value of a variable reference. A value that is not a
+// number is evaluated as an expression; unset and empty variables are 0.
+func (a *arithmetic) variable(t arithToken) (int, error) {
+	value, _ := a.s.paramValue(t.text)
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	if n, err := strconv.Atoi(value); err == nil {
+		return n, nil
+	}
+	return a.s.evalArithDepth(value, a.depth+1)
+}
+
+func (a *arithmetic) store(t arithToken, n int) error {
+	if a.skip > 0 {
+		return nil
+	}
+	name, index := splitSubscript(t.text)
+	if index != "" {
+		return a.s.setElement(name, index, strconv.Itoa(n))
+	}
+	return a.s.setVar(name, strconv.Itoa(n))
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
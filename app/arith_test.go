@@ -0,0 +1,127 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestShell_evalArith(t *testing.T) {
+	tests := map[string]struct {
+		expr     string
+		expected int
+	}{
+		"happy path - precedence":            {expr: "1+2*3", expected: 7},
+		"happy path - parentheses":           {expr: "(1+2)*3", expected: 9},
+		"happy path - power is right assoc":  {expr: "2**3**2", expected: 512},
+		"happy path - unary before power":    {expr: "-2**2", expected: 4},
+		"edge case - huge exponent":          {expr: "2**4000000000", expected: 0},
+		"edge case - power wraps around":     {expr: "3**41", expected: -420491770248316829},
+		"edge case - odd power of minus one": {expr: "(-1)**4000000001", expected: -1},
+		"happy path - division truncates":    {expr: "-7/2", expected: -3},
+		"happy path - remainder":             {expr: "7%3", expected: 1},
+		"happy path - comparison":            {expr: "2<3 && 3>=3", expected: 1},
+		"happy path - equality":              {expr: "1==2 || 1!=2", expected: 1},
+		"happy path - bitwise":               {expr: "6&3 | 8^1", expected: 11},
+		"happy path - shifts":                {expr: "1<<4 >> 2", expected: 4},
+		"happy path - not and complement":    {expr: "!0 + ~0", expected: 0},
+		"happy path - ternary":               {expr: "0 ? 1 : 2 ? 3 : 4", expected: 3},
+		"happy path - comma":                 {expr: "1, 2", expected: 2},
+		"happy path - hexadecimal":           {expr: "0xff", expected: 255},
+		"happy path - octal":                 {expr: "010", expected: 8},
+		"happy path - base":                  {expr: "2#1010 + 36#z", expected: 45},
+		"happy path - base above 36":         {expr: "64#A + 64#@", expected: 98},
+		"happy path - variable":              {expr: "X * 2", expected: 10},
+		"happy path - variable expression":   {expr: "EXPR", expected: 6},
+		"happy path - array element":         {expr: "A[1] + A[X-4]", expected: 40},
+		"happy path - unset variable is 0":   {expr: "UNSET_XYZ + 1", expected: 1},
+		"happy path - double negative":       {expr: "--5", expected: 5},
+		"edge case - empty expression":       {expr: "  ", expected: 0},
+		"edge case - untaken division by 0":  {expr: "0 && 1/0", expected: 0},
+		"edge case - untaken ternary branch": {expr: "1 ? 2 : 1/0", expected: 2},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			shell.setVar("X", "5")
+			shell.setVar("EXPR", "X+1")
+			shell.assign("A=(10 20 30)")
+
+			result, err := shell.evalArith(tc.expr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("expected %d, got %d", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestShell_evalArith_Assignment(t *testing.T) {
+	tests := map[string]struct {
+		expr          string
+		expected      int
+		expectedValue string
+	}{
+		"happy path - assign":                {expr: "N = 3 * 4", expected: 12, expectedValue: "12"},
+		"happy path - compound assign":       {expr: "N += 5", expected: 6, expectedValue: "6"},
+		"happy path - shift assign":          {expr: "N <<= 2", expected: 4, expectedValue: "4"},
+		"happy path - pre-increment":         {expr: "++N", expected: 2, expectedValue: "2"},
+		"happy path - post-increment":        {expr: "N++", expected: 1, expectedValue: "2"},
+		"happy path - post-decrement":        {expr: "N--", expected: 1, expectedValue: "0"},
+		"happy path - chained assignment":    {expr: "M = N = 7", expected: 7, expectedValue: "7"},
+		"edge case - untaken assignment":     {expr: "0 && (N = 9)", expected: 0, expectedValue: "1"},
+		"edge case - untaken post-increment": {expr: "1 || N++", expected: 1, expectedValue: "1"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			shell.setVar("N", "1")
+
+			result, err := shell.evalArith(tc.expr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("expected %d, got %d", tc.expected, result)
+			}
+			if value, _ := shell.getVar("N"); value != tc.expectedValue {
+				t.Errorf("expected N=%q, got %q", tc.expectedValue, value)
+			}
+		})
+	}
+}
+
+func TestShell_evalArith_Errors(t *testing.T) {
+	tests := map[string]struct {
+		expr        string
+		expectedErr string
+	}{
+		"sad path - division by zero":   {expr: "1/0", expectedErr: `1/0: division by 0 (error token is "0")`},
+		"sad path - missing operand":    {expr: "1 +", expectedErr: "1 +: syntax error: operand expected"},
+		"sad path - trailing operand":   {expr: "1 2", expectedErr: `1 2: syntax error in expression (error token is "2")`},
+		"sad path - digit too great":    {expr: "09", expectedErr: `09: value too great for base (error token is "09")`},
+		"sad path - invalid base":       {expr: "65#1", expectedErr: `65#1: invalid arithmetic base (error token is "65#1")`},
+		"sad path - missing paren":      {expr: "(1", expectedErr: "(1: missing `)'"},
+		"sad path - invalid operator":   {expr: "1 $ 2", expectedErr: `1 $ 2: syntax error: invalid arithmetic operator (error token is "$ 2")`},
+		"sad path - negative exponent":  {expr: "2**-1", expectedErr: `2**-1: exponent less than 0 (error token is "**-1")`},
+		"sad path - recursive variable": {expr: "LOOP", expectedErr: "LOOP: expression recursion level exceeded"},
+		"sad path - incomplete ternary": {expr: "1 ? 2", expectedErr: "1 ? 2: `:' expected for conditional expression"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			shell.setVar("LOOP", "LOOP")
+
+			_, err := shell.evalArith(tc.expr)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if err.Error() != tc.expectedErr {
+				t.Errorf("expected error %q, got %q", tc.expectedErr, err.Error())
+			}
+		})
+	}
+}
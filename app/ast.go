@@ -0,0 +1,138 @@
+package main
+
+// Node is an executable element of the syntax tree produced by the parser
+type Node interface{}
+
+// List is a sequence of and-or lists separated by ';' or newlines
+type List struct {
+	Items []*AndOr
+}
+
+// AndOr is a chain of pipelines joined by "&&" and "||"; Ops[i] sits
+// between Pipelines[i] and Pipelines[i+1]
+type AndOr struct {
+	Pipelines []*Pipeline
+	Ops       []string
+}
+
+// Subshell is a list in parentheses, run in a copy of the shell so that
+// its changes to variables, options and the working directory do not last
+type Subshell struct {
+	Body *List
+}
+
+// Group is a list in braces, run in the current shell. It lets one
+// redirection apply to several commands.
+type Group struct {
+	Body *List
+}
+
+// If is an if command. The body of the first clause whose condition
+// succeeds runs; when none does, Else runs if there is one.
+type If struct {
+	Clauses []IfClause
+	Else    *List
+}
+
+// IfClause is the "if" or an "elif" part of an if command
+type IfClause struct {
+	Cond *List
+	Body *List
+}
+
+// For is a for loop, which runs Body with the variable Var set to each
+// field of the expanded Words in turn
+type For struct {
+	Var   string
+	Words []string
+	Body  *List
+}
+
+// ArithFor is an arithmetic for loop "for (( init; cond; step ))". An empty
+// Cond is true.
+type ArithFor struct {
+	Init, Cond, Step string
+	Body             *List
+}
+
+// While is a while loop, which runs Body as long as Cond succeeds, or for an
+// until loop as long as it fails
+type While struct {
+	Cond  *List
+	Body  *List
+	Until bool
+}
+
+// Case is a case command, which matches the expanded Word against the
+// patterns of each item in turn and runs the body of the first match
+type Case struct {
+	Word  string
+	Items []CaseItem
+}
+
+// CaseItem is a list of patterns and the body that runs when one of them
+// matches. Term says what follows the body: ";;" ends the case command,
+// ";&" runs the next body as well and ";;&" goes on testing the next items.
+type CaseItem struct {
+	Patterns []string
+	Body     *List
+	Term     string
+}
+
+// FuncDef is a function definition. Body is the compound command, with its
+// redirections, run when the function is called; Source is its text as
+// written, which type prints.
+type FuncDef struct {
+	Name   string
+	Body   *Command
+	Source string
+}
+
+// CondCommand is a conditional command "[[ expr ]]", which succeeds when
+// the expression is true
+type CondCommand struct {
+	Expr *CondExpr
+}
+
+// CondExpr is an expression of a [[ ]] command. Op is "&&" or "||" joining
+// X and Y, "!" negating X, a unary test such as "-f" of Args[0], a binary
+// test such as "==" between Args[0] and Args[1], or "" for the test that
+// the single word in Args is not empty. Args hold the words unexpanded.
+type CondExpr struct {
+	Op   string
+	X, Y *CondExpr
+	Args []string
+}
+
+// ArithCommand is an arithmetic command "(( expr ))", which succeeds when
+// the expression is not zero
+type ArithCommand struct {
+	Expr string
+}
+
+// Redirect is a redirection of file descriptor Fd by the operator Op to the
+// word Target, e.g. "2>&1" is {Fd: 2, Op: ">&", Target: "1"}. For a
+// here-document Target is the delimiter and Here holds the body.
+type Redirect struct {
+	Fd     int
+	Op     string
+	Target string
+	Here   *HereDoc
+}
+
+// HereDoc is a here-document, whose body the lexer reads from the lines
+// after the command. Quoted is set when the delimiter had quotes, which
+// keeps the body from being expanded.
+type HereDoc struct {
+	Delim     string
+	StripTabs bool
+	Quoted    bool
+	Body      string
+}
+
+// Pipeline is a chain of commands connected through Command.Next. A negated
+// pipeline ("! cmd") inverts the status of its last command.
+type Pipeline struct {
+	Negate bool
+	Cmd    *Command
+}
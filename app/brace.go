@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// expandBraces performs brace expansion on a raw word, returning the words
+// it expands to in order. "{a,b}" produces one word per alternative and
+// "{1..10..2}" or "{a..z}" a sequence. Quoted and escaped braces, those of
+// "${...}", those inside substitutions, which are expanded when the
+// commands run, and braces with neither a comma nor a sequence are left
+// alone.
+func expandBraces(word string) []string {
+	for i := 0; i < len(word); i++ {
+		if j := skipBraceLiteral(word, i); j > i {
+			i = j - 1
+			continue
+		}
+		if word[i] != '{' {
+			continue
+		}
+		alternatives, end, ok := braceAlternatives(word, i)
+		if !ok {
+			continue
+		}
+
+		preamble := word[:i]
+		postscripts := expandBraces(word[end+1:])
+		var words []string
+		for _, alternative := range alternatives {
+			for _, expanded := range expandBraces(alternative) {
+				for _, postscript := range postscripts {
+					words = append(words, preamble+expanded+postscript)
+				}
+			}
+		}
+		return words
+	}
+	return []string{word}
+}
+
+// braceAlternatives parses the brace expression opening at word[open]. It
+// returns the alternatives and the index of the closing brace, or false if
+// the braces do not form a comma list or a sequence.
+func braceAlternatives(word string, open int) ([]string, int, bool) {
+	var parts []string
+	depth, start := 0, open+1
+	for i := open + 1; i < len(word); i++ {
+		if j := skipBraceLiteral(word, i); j > i {
+			i = j - 1
+			continue
+		}
+		switch word[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if parts == nil {
+				seq, ok := braceSequence(word[open+1 : i])
+				return seq, i, ok
+			}
+			return append(parts, word[start:i]), i, true
+		case ',':
+			if depth == 0 {
+				parts = append(parts, word[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+// braceSequence expands the body of a sequence expression, "x..y" or
+// "x..y..incr", where x and y are both integers or both single letters.
+// Integers starting with a zero are padded to the width of the wider end.
+func braceSequence(body string) ([]string, bool) {
+	parts := strings.Split(body, "..")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, false
+	}
+	step := 1
+	if len(parts) == 3 {
+		n, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, false
+		}
+		step = max(n, -n, 1)
+	}
+
+	x, errX := strconv.Atoi(parts[0])
+	y, errY := strconv.Atoi(parts[1])
+	if errX == nil && errY == nil {
+		width := 0
+		if isZeroPadded(parts[0]) || isZeroPadded(parts[1]) {
+			width = max(len(parts[0]), len(parts[1]))
+		}
+		var seq []string
+		for _, n := range sequence(x, y, step) {
+			seq = append(seq, fmt.Sprintf("%0*d", width, n))
+		}
+		return seq, true
+	}
+
+	if len(parts[0]) == 1 && len(parts[1]) == 1 && isLetter(parts[0][0]) && isLetter(parts[1][0]) {
+		var seq []string
+		for _, n := range sequence(int(parts[0][0]), int(parts[1][0]), step) {
+			seq = append(seq, string(rune(n)))
+		}
+		return seq, true
+	}
+	return nil, false
+}
+
+// sequence counts from x to y inclusive in steps of step, downwards when y
+// is below x
+func sequence(x, y, step int) []int {
+	var seq []int
+	if x <= y {
+		for n := x; n <= y; n += step {
+			seq = append(seq, n)
+		}
+	} else {
+		for n := x; n >= y; n -= step {
+			seq = append(seq, n)
+		}
+	}
+	return seq
+}
+
+func isZeroPadded(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	return len(s) > 1 && s[0] == '0'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// skipBraceLiteral returns the index just past the quoted or escaped text,
+// "${...}" parameter or substitution starting at word[i], or i if none
+// starts there
+func skipBraceLiteral(word string, i int) int {
+	l := &lexer{src: word, pos: i}
+	switch word[i] {
+	case Backslash:
+		return min(i+2, len(word))
+	case SingleQuote:
+		end := strings.IndexByte(word[i+1:], SingleQuote)
+		if end < 0 {
+			return len(word)
+		}
+		return i + end + 2
+	case DoubleQuote:
+		l.skipDoubleQuoted()
+		return min(l.pos, len(word))
+	case '$':
+		if i+1 < len(word) && (word[i+1] == '{' || word[i+1] == '(') {
+			l.skipDollar()
+			return min(l.pos, len(word))
+		}
+	case Backquote:
+		if l.skipBackquote() != nil {
+			return len(word)
+		}
+		return l.pos
+	}
+	return i
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestExpandBraces(t *testing.T) {
+	tests := map[string]struct {
+		word     string
+		expected []string
+	}{
+		"happy path - comma list":             {word: "a{b,c}d", expected: []string{"abd", "acd"}},
+		"happy path - path components":        {word: "src/{cmd,pkg}", expected: []string{"src/cmd", "src/pkg"}},
+		"happy path - nested":                 {word: "{a,{b,c}}", expected: []string{"a", "b", "c"}},
+		"happy path - several braces":         {word: "{a,b}{1,2}", expected: []string{"a1", "a2", "b1", "b2"}},
+		"happy path - numeric sequence":       {word: "{1..4}", expected: []string{"1", "2", "3", "4"}},
+		"happy path - descending sequence":    {word: "{3..1}", expected: []string{"3", "2", "1"}},
+		"happy path - sequence increment":     {word: "{1..10..4}", expected: []string{"1", "5", "9"}},
+		"happy path - zero padded":            {word: "{08..10}", expected: []string{"08", "09", "10"}},
+		"happy path - negative sequence":      {word: "{-1..1}", expected: []string{"-1", "0", "1"}},
+		"happy path - letter sequence":        {word: "{a..e..2}", expected: []string{"a", "c", "e"}},
+		"happy path - empty alternative":      {word: "x{,y}", expected: []string{"x", "xy"}},
+		"happy path - quoted alternative":     {word: `{'a b',c}`, expected: []string{`'a b'`, "c"}},
+		"edge case - inner brace only":        {word: "{{a,b}}", expected: []string{"{a}", "{b}"}},
+		"edge case - single quoted":           {word: "'{a,b}'", expected: []string{"'{a,b}'"}},
+		"edge case - double quoted":           {word: `"{a,b}"`, expected: []string{`"{a,b}"`}},
+		"edge case - escaped brace":           {word: `\{a,b}`, expected: []string{`\{a,b}`}},
+		"edge case - parameter braces":        {word: "${a,b}", expected: []string{"${a,b}"}},
+		"edge case - command substitution":    {word: `$(printf "%s-" {a,b})`, expected: []string{`$(printf "%s-" {a,b})`}},
+		"edge case - arithmetic substitution": {word: "$(( {1,2} ))", expected: []string{"$(( {1,2} ))"}},
+		"edge case - backquotes":              {word: "`echo {a,b}`", expected: []string{"`echo {a,b}`"}},
+		"edge case - braces beside one":       {word: "{a,b}$(x)", expected: []string{"a$(x)", "b$(x)"}},
+		"edge case - no comma":                {word: "{a}", expected: []string{"{a}"}},
+		"edge case - unterminated":            {word: "{a,b", expected: []string{"{a,b"}},
+		"edge case - mixed sequence":          {word: "{a..9}", expected: []string{"{a..9}"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			result := expandBraces(tc.word)
+			if len(result) != len(tc.expected) {
+				t.Fatalf("expected %q, got %q", tc.expected, result)
+			}
+			for i := range result {
+				if result[i] != tc.expected[i] {
+					t.Errorf("expected %q, got %q", tc.expected, result)
+				}
+			}
+		})
+	}
+}
@@ -5,96 +5,708 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+
+	"github.com/chzyer/readline"
 )
 
 var builtinCommands = map[string]struct{}{
-	"type":    {},
-	"echo":    {},
-	"exit":    {},
-	"pwd":     {},
-	"cd":      {},
-	"history": {},
+	"type":     {},
+	"echo":     {},
+	"exit":     {},
+	"pwd":      {},
+	"cd":       {},
+	"history":  {},
+	"set":      {},
+	"export":   {},
+	"unset":    {},
+	"readonly": {},
+	"declare":  {},
+	"typeset":  {},
+	"env":      {},
+	"shopt":    {},
+	"let":      {},
+	"shift":    {},
+	"break":    {},
+	"continue": {},
+	"read":     {},
+	"return":   {},
+	"local":    {},
+	"test":     {},
+	"[":        {},
 }
 
-func (s *Shell) handleExit(args []string) {
+// shellOptions lists the option names accepted by "set -o"
+var shellOptions = []string{"noclobber", "pipefail"}
+
+// shortOptions maps the single-letter flags of "set" to option names
+var shortOptions = map[rune]string{'C': "noclobber"}
+
+// shoptOptions lists the option names accepted by "shopt"
+var shoptOptions = []string{"dotglob", "extglob", "failglob", "globstar", "nullglob"}
+
+// handleExit ends the shell. In a subshell it only returns the status, and
+// runStage unwinds the subshell with it.
+func (s *Shell) handleExit(args []string, ioc *ioContext) int {
+	status := s.lastStatus
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Fprintln(ioc.stderr, "exit: incorrect command arguments")
+			return 1
+		}
+		status = v
+	}
+	if s.inSubshell {
+		return status
+	}
+
 	// Save history to HISTFILE if set
-	if histfile := os.Getenv("HISTFILE"); histfile != "" {
+	if histfile := os.Getenv("HISTFILE"); histfile != "" && s.interactive {
 		content := strings.Join(s.history, "\n") + "\n"
 		os.WriteFile(histfile, []byte(content), 0o644)
 	}
+	os.Exit(status)
+	return 0
+}
+
+func (s *Shell) handleEcho(args []string, ioc *ioContext) int {
+	fmt.Fprintln(ioc.stdout, strings.Join(args, " "))
+	return 0
+}
 
+func (s *Shell) handleType(args []string, ioc *ioContext) int {
 	if len(args) == 0 {
-		os.Exit(0)
-		return
+		fmt.Fprintln(ioc.stdout, "no command found")
+		return 0
 	}
-	v, err := strconv.Atoi(args[0])
-	if err != nil {
-		fmt.Printf("incorrect command arguments")
-		return
+	commandName := args[0]
+	filePath := s.isInPath(commandName)
+	if fn, ok := s.funcs[commandName]; ok {
+		fmt.Fprintf(ioc.stdout, "%[1]s is a function\n%[1]s () \n%[2]s\n", commandName, fn.Source)
+	} else if _, ok := builtinCommands[commandName]; ok {
+		fmt.Fprintf(ioc.stdout, "%s is a shell builtin\n", commandName)
+	} else if filePath != "" {
+		fmt.Fprintf(ioc.stdout, "%[1]s is %[2]s\n", commandName, filePath)
+	} else {
+		fmt.Fprintf(ioc.stdout, "%s: not found\n", commandName)
+		return 1
 	}
-	os.Exit(v)
+	return 0
 }
 
-func (s *Shell) handleEcho(cmd Command, stdout io.Writer) {
-	output := strings.Join(cmd.Args, " ") + "\n"
-	if cmd.RedirectFile != "" && !cmd.RedirectStderr {
-		s.writeToFile(cmd.RedirectFile, []byte(output), cmd.AppendMode)
-	} else {
-		if cmd.RedirectFile != "" {
-			s.writeToFile(cmd.RedirectFile, []byte(""), cmd.AppendMode)
+func (s *Shell) handlePwd(args []string, ioc *ioContext) int {
+	fmt.Fprintf(ioc.stdout, "%s\n", s.cwd)
+	return 0
+}
+
+func (s *Shell) handleCd(args []string, ioc *ioContext) int {
+	dir, _ := s.getVar("HOME")
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	// The working directory belongs to the shell, not the process, so that
+	// a subshell can change its own copy
+	target := s.resolvePath(dir)
+	info, err := os.Stat(target)
+	switch {
+	case err != nil:
+		fmt.Fprintf(ioc.stderr, "cd: %s: No such file or directory\n", dir)
+		return 1
+	case !info.IsDir():
+		fmt.Fprintf(ioc.stderr, "cd: %s: Not a directory\n", dir)
+		return 1
+	}
+
+	// Keep PWD and OLDPWD current for "~+" and "~-"
+	s.setVar("OLDPWD", s.cwd)
+	s.cwd = target
+	s.setVar("PWD", target)
+	return 0
+}
+
+// handleSet changes options, named by "set -o" or as single-letter flags
+// like "set -C". Arguments after "--", or from the first one that is not an
+// option, replace the positional parameters.
+func (s *Shell) handleSet(args []string, ioc *ioContext) int {
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" || args[i] == "-" {
+			s.params = slices.Clone(args[i+1:])
+			return 0
+		}
+		if !strings.HasPrefix(args[i], "-") && !strings.HasPrefix(args[i], "+") {
+			s.params = slices.Clone(args[i:])
+			return 0
+		}
+
+		enable := args[i][0] == '-'
+		if args[i][1:] != "o" {
+			for _, c := range args[i][1:] {
+				name, ok := shortOptions[c]
+				if !ok {
+					fmt.Fprintf(ioc.stderr, "set: %s: invalid option\n", args[i])
+					return 2
+				}
+				s.options[name] = enable
+			}
+			continue
+		}
+		if i+1 >= len(args) {
+			s.printOptions(enable, ioc.stdout)
+			return 0
+		}
+		i++
+		if !slices.Contains(shellOptions, args[i]) {
+			fmt.Fprintf(ioc.stderr, "set: %s: invalid option name\n", args[i])
+			return 1
+		}
+		s.options[args[i]] = enable
+	}
+	return 0
+}
+
+// printOptions lists the "set -o" options, either as a table ("set -o") or
+// as commands that recreate the current settings ("set +o")
+func (s *Shell) printOptions(table bool, stdout io.Writer) {
+	for _, name := range shellOptions {
+		switch {
+		case table && s.options[name]:
+			fmt.Fprintf(stdout, "%-15s\ton\n", name)
+		case table:
+			fmt.Fprintf(stdout, "%-15s\toff\n", name)
+		case s.options[name]:
+			fmt.Fprintf(stdout, "set -o %s\n", name)
+		default:
+			fmt.Fprintf(stdout, "set +o %s\n", name)
 		}
-		fmt.Fprint(stdout, output)
 	}
 }
 
-func (s *Shell) handleType(args []string, stdout io.Writer) {
+func (s *Shell) handleShopt(args []string, ioc *ioContext) int {
+	var mode byte
+	quiet, print := false, false
+	valid := shoptOptions
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		for _, c := range args[0][1:] {
+			switch c {
+			case 's', 'u':
+				mode = byte(c)
+			case 'q':
+				quiet = true
+			case 'p':
+				print = true
+			case 'o':
+				valid = shellOptions
+			default:
+				fmt.Fprintf(ioc.stderr, "shopt: -%c: invalid option\n", c)
+				return 2
+			}
+		}
+		args = args[1:]
+	}
+
+	names := args
+	for _, name := range names {
+		if !slices.Contains(valid, name) {
+			fmt.Fprintf(ioc.stderr, "shopt: %s: invalid shell option name\n", name)
+			return 1
+		}
+	}
+
+	if mode != 0 && len(names) > 0 {
+		for _, name := range names {
+			s.options[name] = mode == 's'
+		}
+		return 0
+	}
+
+	if len(names) == 0 {
+		// With -s or -u alone, list only the options in that state
+		for _, name := range valid {
+			if mode == 0 || s.options[name] == (mode == 's') {
+				names = append(names, name)
+			}
+		}
+	}
+	status := 0
+	for _, name := range names {
+		if !s.options[name] {
+			status = 1
+		}
+		switch {
+		case quiet:
+		case print && s.options[name]:
+			fmt.Fprintf(ioc.stdout, "shopt -s %s\n", name)
+		case print:
+			fmt.Fprintf(ioc.stdout, "shopt -u %s\n", name)
+		case s.options[name]:
+			fmt.Fprintf(ioc.stdout, "%-15s\ton\n", name)
+		default:
+			fmt.Fprintf(ioc.stdout, "%-15s\toff\n", name)
+		}
+	}
 	if len(args) == 0 {
-		fmt.Fprintln(stdout, "no command found")
-		return
+		return 0
 	}
-	commandName := args[0]
-	filePath := s.isInPath(commandName)
-	if _, ok := builtinCommands[commandName]; ok {
-		fmt.Fprintf(stdout, "%s is a shell builtin\n", commandName)
-	} else if filePath != "" {
-		fmt.Fprintf(stdout, "%[1]s is %[2]s\n", commandName, filePath)
-	} else {
-		fmt.Fprintf(stdout, "%s: not found\n", commandName)
+	return status
+}
+
+func (s *Shell) handleExport(args []string, ioc *ioContext) int {
+	attrs, print, names, ok := parseAttrFlags("export", "np", args, ioc.stderr)
+	if !ok {
+		return 2
+	}
+	set, clear := "x", ""
+	if strings.ContainsRune(attrs, 'n') {
+		set, clear = "", "x"
 	}
+	if print || len(names) == 0 {
+		s.printVars("x", ioc.stdout)
+		return 0
+	}
+	return s.declareAll("export", names, set, clear, ioc.stderr)
 }
 
-func (s *Shell) handlePwd(stdout io.Writer) {
-	dir, err := os.Getwd()
-	if err == nil {
-		fmt.Fprintf(stdout, "%s\n", dir)
-	} else {
-		fmt.Fprintf(stdout, "error getting pwd %s\n", err)
+func (s *Shell) handleReadonly(args []string, ioc *ioContext) int {
+	attrs, print, names, ok := parseAttrFlags("readonly", "aAp", args, ioc.stderr)
+	if !ok {
+		return 2
+	}
+	if print || len(names) == 0 {
+		s.printVars("r", ioc.stdout)
+		return 0
 	}
+	return s.declareAll("readonly", names, attrs+"r", "", ioc.stderr)
 }
 
-func (s *Shell) handleCd(args []string, stderr io.Writer) {
-	dir := os.Getenv("HOME")
-	if len(args) > 0 && args[0] != "~" {
-		dir = args[0]
+func (s *Shell) handleDeclare(name string, args []string, ioc *ioContext) int {
+	attrs, print, names, ok := parseAttrFlags(name, "aAirxp", args, ioc.stderr)
+	if !ok {
+		return 2
+	}
+	set, clear, _ := strings.Cut(attrs, "+")
+	if name == "local" && len(s.locals) == 0 {
+		fmt.Fprintln(ioc.stderr, "local: can only be used in a function")
+		return 1
+	}
+
+	if len(names) == 0 {
+		s.printVars(set, ioc.stdout)
+		return 0
+	}
+	if print {
+		status := 0
+		for _, arg := range names {
+			if v, ok := s.vars[arg]; ok {
+				fmt.Fprintln(ioc.stdout, declareLine(arg, v))
+			} else {
+				fmt.Fprintf(ioc.stderr, "%s: %s: not found\n", name, arg)
+				status = 1
+			}
+		}
+		return status
+	}
+	// In a function declare creates local variables, as local does
+	if len(s.locals) > 0 {
+		for _, arg := range names {
+			s.makeLocal(arg)
+		}
 	}
-	if err := os.Chdir(dir); err != nil {
-		fmt.Fprintf(stderr, "cd: %s: No such file or directory\n", dir)
+	return s.declareAll(name, names, set, clear, ioc.stderr)
+}
+
+// parseAttrFlags parses the leading -x/+x style options of declare and its
+// relatives. attrs holds the letters turned on, then "+" and the letters
+// turned off; print reports -p.
+func parseAttrFlags(cmd, allowed string, args []string, stderr io.Writer) (attrs string, print bool, names []string, ok bool) {
+	var set, clear string
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			i++
+			break
+		}
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			break
+		}
+		for _, c := range arg[1:] {
+			switch {
+			case !strings.ContainsRune(allowed, c):
+				fmt.Fprintf(stderr, "%s: %c%c: invalid option\n", cmd, arg[0], c)
+				return "", false, nil, false
+			case c == 'p':
+				print = true
+			case arg[0] == '-':
+				set += string(c)
+			default:
+				clear += string(c)
+			}
+		}
+	}
+	return set + "+" + clear, print, args[i:], true
+}
+
+// declareAll runs declare for every name, reporting errors on stderr
+func (s *Shell) declareAll(cmd string, names []string, set, clear string, stderr io.Writer) int {
+	status := 0
+	for _, name := range names {
+		if err := s.declare(name, set, clear); err != nil {
+			fmt.Fprintf(stderr, "%s: %s\n", cmd, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// printVars prints, in declare -p form, every variable that has all the
+// attributes in attrs
+func (s *Shell) printVars(attrs string, stdout io.Writer) {
+	names := make([]string, 0, len(s.vars))
+	for name := range s.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		line := declareLine(name, s.vars[name])
+		flags, _, _ := strings.Cut(strings.TrimPrefix(line, "declare -"), " ")
+		if strings.Trim(attrs, flags) == "" {
+			fmt.Fprintln(stdout, line)
+		}
 	}
 }
 
-func (s *Shell) handleHistory(args []string, stdout io.Writer) {
+func (s *Shell) handleUnset(args []string, ioc *ioContext) int {
+	functions := false
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "-f":
+			functions = true
+		case "-v":
+			functions = false
+		case "--":
+		default:
+			fmt.Fprintf(ioc.stderr, "unset: %s: invalid option\n", args[0])
+			return 2
+		}
+		args = args[1:]
+	}
+	status := 0
+	for _, name := range args {
+		// Without -f a name is a function only when no variable has it
+		if _, isVar := s.vars[name]; functions || !isVar {
+			if _, ok := s.funcs[name]; ok {
+				delete(s.funcs, name)
+				continue
+			}
+		}
+		if functions {
+			continue
+		}
+		if err := s.unsetVar(name); err != nil {
+			fmt.Fprintf(ioc.stderr, "unset: %s\n", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// handleEnv prints the exported environment, or runs a program with
+// NAME=value operands added to its environment. -i starts from an empty
+// environment and -u NAME removes NAME from it.
+func (s *Shell) handleEnv(args []string, ioc *ioContext) int {
+	ignore := false
+	var unset []string
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+		if arg == "-" {
+			ignore = true
+			continue
+		}
+		for i := 1; i < len(arg); i++ {
+			switch arg[i] {
+			case 'i':
+				ignore = true
+			case 'u':
+				name := arg[i+1:]
+				if name == "" {
+					if len(args) == 0 {
+						fmt.Fprintln(ioc.stderr, "env: option requires an argument -- 'u'")
+						return 125
+					}
+					name, args = args[0], args[1:]
+				}
+				unset = append(unset, name)
+				i = len(arg)
+			default:
+				fmt.Fprintf(ioc.stderr, "env: invalid option -- '%c'\n", arg[i])
+				return 125
+			}
+		}
+	}
+
+	// An empty but non-nil environment, as a nil one would be inherited
+	env := []string{}
+	if !ignore {
+		for _, kv := range s.environ(nil) {
+			if name, _, _ := strings.Cut(kv, "="); !slices.Contains(unset, name) {
+				env = append(env, kv)
+			}
+		}
+	}
+	for len(args) > 0 && strings.Contains(args[0], "=") {
+		env = append(env, args[0])
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		for _, kv := range env {
+			fmt.Fprintln(ioc.stdout, kv)
+		}
+		return 0
+	}
+	if s.isInPath(args[0]) == "" && !strings.Contains(args[0], "/") {
+		fmt.Fprintf(ioc.stderr, "env: '%s': No such file or directory\n", args[0])
+		return 127
+	}
+	return s.runExternal(Command{Name: args[0], Args: args[1:]}, env, ioc.fds)
+}
+
+// handleShift drops the first n positional parameters, 1 by default
+func (s *Shell) handleShift(args []string, ioc *ioContext) int {
+	n := 1
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 0 {
+			fmt.Fprintf(ioc.stderr, "shift: %s: numeric argument required\n", args[0])
+			return 1
+		}
+	}
+	if n > len(s.params) {
+		return 1
+	}
+	s.params = s.params[n:]
+	return 0
+}
+
+// handleLet evaluates each argument as an arithmetic expression. It fails
+// when the last one evaluates to 0.
+func (s *Shell) handleLet(args []string, ioc *ioContext) int {
+	if len(args) == 0 {
+		fmt.Fprintln(ioc.stderr, "let: expression expected")
+		return 1
+	}
+	n := 0
+	for _, arg := range args {
+		var err error
+		if n, err = s.evalArith(arg); err != nil {
+			fmt.Fprintf(ioc.stderr, "let: %s\n", err)
+			return 1
+		}
+	}
+	return boolStatus(n != 0)
+}
+
+// handleBreak returns the loopControl that leaves the nth enclosing loop,
+// or for continue starts its next iteration; n is 1 by default
+func (s *Shell) handleBreak(name string, args []string, ioc *ioContext) error {
+	if s.loopDepth == 0 {
+		fmt.Fprintf(ioc.stderr, "%s: only meaningful in a `for', `while', or `until' loop\n", name)
+		return nil
+	}
+	n := 1
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil {
+			fmt.Fprintf(ioc.stderr, "%s: %s: numeric argument required\n", name, args[0])
+			return exitStatus(1)
+		}
+		if n < 1 {
+			fmt.Fprintf(ioc.stderr, "%s: %s: loop count out of range\n", name, args[0])
+			return exitStatus(1)
+		}
+	}
+	return loopControl{Continue: name == "continue", Levels: min(n, s.loopDepth)}
+}
+
+// handleReturn returns the funcReturn that ends the function being run
+// with status n, by default the status of the last command
+func (s *Shell) handleReturn(args []string, ioc *ioContext) error {
+	if len(s.locals) == 0 {
+		fmt.Fprintln(ioc.stderr, "return: can only `return' from a function or sourced script")
+		return exitStatus(2)
+	}
+	status := s.lastStatus
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Fprintf(ioc.stderr, "return: %s: numeric argument required\n", args[0])
+			return funcReturn(2)
+		}
+		status = n & 0xff
+	}
+	return funcReturn(status)
+}
+
+// handleRead reads a line from standard input into the named variables,
+// REPLY by default, showing the -p prompt first. The line is split on IFS
+// and the last name gets the rest of it. Unless -r is given a backslash
+// quotes the next character and a backslash-newline continues the line. It
+// fails at end of input.
+func (s *Shell) handleRead(args []string, ioc *ioContext) int {
+	raw, prompt := false, ""
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		flags := args[0][1:]
+		args = args[1:]
+		if flags == "-" {
+			break
+		}
+		for flags != "" {
+			c := flags[0]
+			flags = flags[1:]
+			switch c {
+			case 'r':
+				raw = true
+			case 'p':
+				prompt, flags = flags, ""
+				if prompt == "" && len(args) > 0 {
+					prompt, args = args[0], args[1:]
+				}
+			default:
+				fmt.Fprintf(ioc.stderr, "read: -%c: invalid option\n", c)
+				return 2
+			}
+		}
+	}
+	for _, name := range args {
+		if !isName(name) {
+			fmt.Fprintf(ioc.stderr, "read: `%s': not a valid identifier\n", name)
+			return 1
+		}
+	}
+
+	// The prompt is only shown when reading from a terminal
+	if f, ok := ioc.stdin.(*os.File); ok && prompt != "" && readline.IsTerminal(int(f.Fd())) {
+		fmt.Fprint(ioc.stderr, prompt)
+	}
+	line, escaped, err := readLine(ioc.stdin, raw)
+	if err != nil && err != io.EOF {
+		fmt.Fprintf(ioc.stderr, "read: read error: 0: %s\n", strerror(err))
+		return 1
+	}
+	ok := err == nil
+	if len(args) == 0 {
+		s.setVar("REPLY", string(line))
+		return boolStatus(ok)
+	}
+	ifs, set := s.getVar("IFS")
+	if !set {
+		ifs = " \t\n"
+	}
+	fields := splitRead(line, escaped, ifs, len(args))
+	for i, name := range args {
+		value := ""
+		if i < len(fields) {
+			value = fields[i]
+		}
+		if err := s.setVar(name, value); err != nil {
+			fmt.Fprintf(ioc.stderr, "read: %s\n", err)
+			return 1
+		}
+	}
+	return boolStatus(ok)
+}
+
+// readLine reads one line from r a byte at a time, so that nothing after it
+// is consumed. The error is io.EOF if the line did not end with a newline.
+// Unless raw is set, backslashes are removed and escaped marks the
+// characters they quote.
+func readLine(r io.Reader, raw bool) (line []byte, escaped []bool, err error) {
+	var b [1]byte
+	for {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return line, escaped, err
+		}
+		if b[0] == '\n' {
+			return line, escaped, nil
+		}
+		quoted := false
+		if b[0] == Backslash && !raw {
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return line, escaped, err
+			}
+			if b[0] == '\n' {
+				continue
+			}
+			quoted = true
+		}
+		line = append(line, b[0])
+		escaped = append(escaped, quoted)
+	}
+}
+
+// splitRead splits a line read by read into at most n fields. IFS
+// whitespace around the fields is dropped; the last field is the rest of
+// the line, with its separators kept.
+func splitRead(line []byte, escaped []bool, ifs string, n int) []string {
+	isSep := func(i int) bool {
+		return !escaped[i] && strings.IndexByte(ifs, line[i]) >= 0
+	}
+	isSpace := func(i int) bool {
+		return isSep(i) && strings.IndexByte(" \t\n", line[i]) >= 0
+	}
+
+	i := 0
+	for i < len(line) && isSpace(i) {
+		i++
+	}
+	var fields []string
+	for len(fields) < n-1 && i < len(line) {
+		start := i
+		for i < len(line) && !isSep(i) {
+			i++
+		}
+		fields = append(fields, string(line[start:i]))
+		// A field ends at IFS whitespace around at most one other IFS
+		// character
+		for i < len(line) && isSpace(i) {
+			i++
+		}
+		if i < len(line) && isSep(i) {
+			for i++; i < len(line) && isSpace(i); i++ {
+			}
+		}
+	}
+	end := len(line)
+	for end > i && isSpace(end-1) {
+		end--
+	}
+	if i < end {
+		fields = append(fields, string(line[i:end]))
+	}
+	return fields
+}
+
+func (s *Shell) handleHistory(args []string, ioc *ioContext) int {
 	if len(args) > 0 && args[0] == "-r" {
 		if len(args) < 2 {
-			fmt.Fprintln(stdout, "history: missing argument")
-			return
+			fmt.Fprintln(ioc.stderr, "history: missing argument")
+			return 1
 		}
 		filePath := args[1]
-		content, err := os.ReadFile(filePath)
+		content, err := os.ReadFile(s.resolvePath(filePath))
 		if err != nil {
-			fmt.Fprintf(stdout, "history: %s\n", err)
-			return
+			fmt.Fprintf(ioc.stderr, "history: %s\n", err)
+			return 1
 		}
 		lines := strings.Split(string(content), "\n")
 		for _, line := range lines {
@@ -102,34 +714,34 @@ func (s *Shell) handleHistory(args []string, stdout io.Writer) {
 				s.history = append(s.history, line)
 			}
 		}
-		return
+		return 0
 	}
 
 	if len(args) > 0 && args[0] == "-w" {
 		if len(args) < 2 {
-			fmt.Fprintln(stdout, "history: missing argument")
-			return
+			fmt.Fprintln(ioc.stderr, "history: missing argument")
+			return 1
 		}
 		filePath := args[1]
 		content := strings.Join(s.history, "\n") + "\n"
-		if err := os.WriteFile(filePath, []byte(content), 0o644); err != nil {
-			fmt.Fprintf(stdout, "history: %s\n", err)
-			return
+		if err := os.WriteFile(s.resolvePath(filePath), []byte(content), 0o644); err != nil {
+			fmt.Fprintf(ioc.stderr, "history: %s\n", err)
+			return 1
 		}
-		return
+		return 0
 	}
 
 	if len(args) > 0 && args[0] == "-a" {
 		if len(args) < 2 {
-			fmt.Fprintln(stdout, "history: missing argument")
-			return
+			fmt.Fprintln(ioc.stderr, "history: missing argument")
+			return 1
 		}
 		filePath := args[1]
 		// append history to file
-		f, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		f, err := os.OpenFile(s.resolvePath(filePath), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
 		if err != nil {
-			fmt.Fprintf(stdout, "history: %s\n", err)
-			return
+			fmt.Fprintf(ioc.stderr, "history: %s\n", err)
+			return 1
 		}
 		defer f.Close()
 
@@ -137,20 +749,20 @@ func (s *Shell) handleHistory(args []string, stdout io.Writer) {
 		if len(newLines) > 0 {
 			content := strings.Join(newLines, "\n") + "\n"
 			if _, err := f.WriteString(content); err != nil {
-				fmt.Fprintf(stdout, "history: %s\n", err)
-				return
+				fmt.Fprintf(ioc.stderr, "history: %s\n", err)
+				return 1
 			}
 			s.historyAppendedCount = len(s.history)
 		}
-		return
+		return 0
 	}
 
 	var num int
 	var err error
 	if len(args) > 0 {
 		if num, err = strconv.Atoi(args[0]); err != nil {
-			fmt.Fprintln(stdout, "history: invalid number")
-			return
+			fmt.Fprintln(ioc.stderr, "history: invalid number")
+			return 1
 		}
 	}
 
@@ -160,33 +772,60 @@ func (s *Shell) handleHistory(args []string, stdout io.Writer) {
 	}
 
 	for i := start; i < len(s.history); i++ {
-		fmt.Fprintf(stdout, "    %d  %s\n", i+1, s.history[i])
+		fmt.Fprintf(ioc.stdout, "    %d  %s\n", i+1, s.history[i])
 	}
+	return 0
 }
 
-func (s *Shell) handleExternal(cmd Command, stdin io.Reader, stdout io.Writer) {
-	execCmd := exec.Command(cmd.Name, cmd.Args...)
-	execCmd.Stdin = stdin
+// handleExternal runs an external program with the descriptors in fds.
+// Files opened by redirections are handed to the child as they are, so its
+// output streams into them instead of passing through the shell.
+func (s *Shell) handleExternal(cmd Command, fds fdTable) int {
+	return s.runExternal(cmd, s.environ(cmd.Env), fds)
+}
 
-	if cmd.RedirectFile != "" {
-		if cmd.RedirectStderr {
-			execCmd.Stdout = stdout
-			if stderr, err := execCmd.StderrPipe(); err == nil {
-				if execCmd.Start() == nil {
-					if data, err := io.ReadAll(stderr); err == nil {
-						s.writeToFile(cmd.RedirectFile, data, cmd.AppendMode)
-					}
-					_ = execCmd.Wait()
-				}
-			}
-		} else {
-			execCmd.Stderr = os.Stderr
-			output, _ := execCmd.Output()
-			s.writeToFile(cmd.RedirectFile, output, cmd.AppendMode)
+// runExternal runs an external program with the environment env
+func (s *Shell) runExternal(cmd Command, env []string, fds fdTable) int {
+	// Run the file found through the shell's PATH, which may differ from
+	// the environment's
+	path := s.isInPath(cmd.Name)
+	if path == "" {
+		path = cmd.Name
+	}
+	// Running a directory fails with EACCES, which hides the reason
+	if info, err := os.Stat(s.resolvePath(path)); strings.Contains(path, "/") && err == nil && info.IsDir() {
+		fmt.Fprintf(fds.writer(2), "%s: %s\n", cmd.Name, strerror(syscall.EISDIR))
+		return 126
+	}
+	execCmd := exec.Command(path, cmd.Args...)
+	execCmd.Args[0] = cmd.Name
+	execCmd.Env = env
+	execCmd.Dir = s.cwd
+	// A closed standard descriptor is given to the child as /dev/null
+	// opened the other way round, so that using it fails with EBADF
+	var streams [3]any
+	for fd := range streams {
+		streams[fd] = fds[fd]
+		if streams[fd] != nil {
+			continue
 		}
-	} else {
-		execCmd.Stdout = stdout
-		execCmd.Stderr = os.Stderr
-		_ = execCmd.Run()
+		flag := os.O_RDONLY
+		if fd == 0 {
+			flag = os.O_WRONLY
+		}
+		if null, err := os.OpenFile(os.DevNull, flag, 0); err == nil {
+			defer null.Close()
+			streams[fd] = null
+		}
+	}
+	execCmd.Stdin, _ = streams[0].(io.Reader)
+	execCmd.Stdout, _ = streams[1].(io.Writer)
+	execCmd.Stderr, _ = streams[2].(io.Writer)
+	execCmd.ExtraFiles = fds.extraFiles()
+
+	status, reason := commandStatus(execCmd.Run())
+	if reason != "" {
+		fmt.Fprintf(fds.writer(2), "%s: %s\n", cmd.Name, reason)
 	}
+	return status
 }
@@ -4,12 +4,21 @@ import (
 	"bytes"
 	"io"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
 
+// testIO returns an I/O context with empty input that writes to stdout and
+// stderr
+func testIO(stdout, stderr io.Writer) *ioContext {
+	return newIOContext(newFdTable(strings.NewReader(""), stdout, stderr))
+}
+
 func TestShell_handleType(t *testing.T) {
 	shell := NewShell()
+	shell.funcs["greet"] = &FuncDef{Name: "greet", Source: "{ echo hi; }"}
 
 	tests := map[string]struct {
 		args     []string
@@ -39,6 +48,10 @@ func TestShell_handleType(t *testing.T) {
 			args:     []string{"cd"},
 			expected: "cd is a shell builtin\n",
 		},
+		"happy path - function": {
+			args:     []string{"greet"},
+			expected: "greet is a function\ngreet () \n{ echo hi; }\n",
+		},
 	}
 
 	for name, tc := range tests {
@@ -48,7 +61,7 @@ func TestShell_handleType(t *testing.T) {
 			r, w, _ := os.Pipe()
 			os.Stdout = w
 
-			shell.handleType(tc.args, w)
+			shell.handleType(tc.args, testIO(w, w))
 
 			w.Close()
 			os.Stdout = old
@@ -86,7 +99,7 @@ func TestShell_handlePwd(t *testing.T) {
 	for name := range tests {
 		t.Run(name, func(t *testing.T) {
 			var buf bytes.Buffer
-			shell.handlePwd(&buf)
+			shell.handlePwd(nil, testIO(&buf, &buf))
 			result := strings.TrimSpace(buf.String())
 
 			// Verify we got a valid directory path
@@ -116,8 +129,8 @@ func TestShell_handleCd(t *testing.T) {
 			args:        []string{},
 			expectError: false,
 		},
-		"happy path - change to home with tilde": {
-			args:        []string{"~"},
+		"happy path - change to expanded home": {
+			args:        []string{os.Getenv("HOME")},
 			expectError: false,
 		},
 		"sad path - nonexistent directory": {
@@ -133,7 +146,7 @@ func TestShell_handleCd(t *testing.T) {
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			var buf bytes.Buffer
-			shell.handleCd(tc.args, &buf)
+			shell.handleCd(tc.args, testIO(&buf, &buf))
 			output := buf.String()
 
 			if tc.expectError {
@@ -167,8 +180,22 @@ func TestShell_handleExternal(t *testing.T) {
 			cmd:          Command{Name: "ls", Args: []string{"/nonexistent"}},
 			expectOutput: true,
 		},
+		"happy path - found through the shell's PATH": {
+			cmd:          Command{Name: "gosh_path_cmd"},
+			expectOutput: true,
+		},
+		"sad path - missing file is reported": {
+			cmd:          Command{Name: "/nonexistent/cmd"},
+			expectOutput: true,
+		},
 	}
 
+	// gosh_path_cmd is only on the shell's PATH, not the process's
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "gosh_path_cmd"), []byte("#!/bin/sh\necho ran\n"), 0755)
+	path, _ := shell.getVar("PATH")
+	shell.setVar("PATH", dir+":"+path)
+
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			// Capture stdout and stderr
@@ -179,7 +206,7 @@ func TestShell_handleExternal(t *testing.T) {
 			os.Stdout = wOut
 			os.Stderr = wErr
 
-			shell.handleExternal(tc.cmd, strings.NewReader(""), wOut)
+			shell.handleExternal(tc.cmd, newFdTable(strings.NewReader(""), wOut, wErr))
 
 			wOut.Close()
 			wErr.Close()
@@ -206,7 +233,7 @@ func TestShell_handleHistory(t *testing.T) {
 	shell.history = []string{"echo hello", "echo world", "invalid_command", "history"}
 
 	var buf bytes.Buffer
-	shell.handleHistory([]string{}, &buf)
+	shell.handleHistory([]string{}, testIO(&buf, &buf))
 
 	expected := "    1  echo hello\n    2  echo world\n    3  invalid_command\n    4  history\n"
 	if buf.String() != expected {
@@ -219,7 +246,7 @@ func TestShell_handleHistory_Limit(t *testing.T) {
 	shell.history = []string{"cmd1", "cmd2", "cmd3", "cmd4", "history 2"}
 
 	var buf bytes.Buffer
-	shell.handleHistory([]string{"2"}, &buf)
+	shell.handleHistory([]string{"2"}, testIO(&buf, &buf))
 
 	expected := "    4  cmd4\n    5  history 2\n"
 	if buf.String() != expected {
@@ -247,7 +274,7 @@ func TestShell_handleHistory_Read(t *testing.T) {
 	}
 
 	var buf bytes.Buffer
-	shell.handleHistory([]string{"-r", tmpfile.Name()}, &buf)
+	shell.handleHistory([]string{"-r", tmpfile.Name()}, testIO(&buf, &buf))
 
 	// Verify history was updated
 	if len(shell.history) != 3 {
@@ -274,7 +301,7 @@ func TestShell_handleHistory_Write(t *testing.T) {
 	tmpfile.Close()
 
 	var buf bytes.Buffer
-	shell.handleHistory([]string{"-w", tmpfile.Name()}, &buf)
+	shell.handleHistory([]string{"-w", tmpfile.Name()}, testIO(&buf, &buf))
 
 	// Read file content
 	content, err := os.ReadFile(tmpfile.Name())
@@ -306,7 +333,7 @@ func TestShell_handleHistory_Append(t *testing.T) {
 	tmpfile.Close()
 
 	var buf bytes.Buffer
-	shell.handleHistory([]string{"-a", tmpfile.Name()}, &buf)
+	shell.handleHistory([]string{"-a", tmpfile.Name()}, testIO(&buf, &buf))
 
 	// Read file content
 	content, err := os.ReadFile(tmpfile.Name())
@@ -319,3 +346,522 @@ func TestShell_handleHistory_Append(t *testing.T) {
 		t.Errorf("expected file content %q, got %q", expected, string(content))
 	}
 }
+
+func TestShell_handleSet(t *testing.T) {
+	shell := NewShell()
+
+	tests := map[string]struct {
+		args           []string
+		option         string
+		expectedStatus int
+		expectedOption bool
+	}{
+		"happy path - enable noclobber by letter": {
+			args:           []string{"-C"},
+			option:         "noclobber",
+			expectedOption: true,
+		},
+		"happy path - disable noclobber by letter": {
+			args:           []string{"-C", "+C"},
+			option:         "noclobber",
+			expectedOption: false,
+		},
+		"happy path - enable pipefail": {
+			args:           []string{"-o", "pipefail"},
+			expectedOption: true,
+		},
+		"happy path - disable pipefail": {
+			args:           []string{"+o", "pipefail"},
+			expectedOption: false,
+		},
+		"sad path - unknown option name": {
+			args:           []string{"-o", "nonexistent_option"},
+			expectedStatus: 1,
+		},
+		"sad path - invalid flag": {
+			args:           []string{"-Z"},
+			expectedStatus: 2,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell.options = map[string]bool{}
+			var buf bytes.Buffer
+			status := shell.handleSet(tc.args, testIO(&buf, &buf))
+			if status != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d (output: %q)", tc.expectedStatus, status, buf.String())
+			}
+			if tc.option == "" {
+				tc.option = "pipefail"
+			}
+			if shell.options[tc.option] != tc.expectedOption {
+				t.Errorf("expected %s=%v, got %v", tc.option, tc.expectedOption, shell.options[tc.option])
+			}
+		})
+	}
+}
+
+func TestShell_handleSet_List(t *testing.T) {
+	shell := NewShell()
+	shell.options["pipefail"] = true
+
+	var buf bytes.Buffer
+	shell.handleSet([]string{"+o"}, testIO(&buf, &buf))
+	expected := "set +o noclobber\nset -o pipefail\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestShell_handleSet_Params(t *testing.T) {
+	tests := map[string]struct {
+		args     []string
+		expected []string
+	}{
+		"happy path - after double dash": {args: []string{"--", "a", "-b"}, expected: []string{"a", "-b"}},
+		"happy path - first non-option":  {args: []string{"-o", "pipefail", "x", "y"}, expected: []string{"x", "y"}},
+		"edge case - double dash clears": {args: []string{"--"}, expected: []string{}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			shell.params = []string{"old"}
+			var buf bytes.Buffer
+			if status := shell.handleSet(tc.args, testIO(&buf, &buf)); status != 0 {
+				t.Fatalf("expected status 0, got %d", status)
+			}
+			if !slices.Equal(shell.params, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, shell.params)
+			}
+		})
+	}
+}
+
+func TestShell_handleShift(t *testing.T) {
+	tests := map[string]struct {
+		args           []string
+		expectedStatus int
+		expected       []string
+	}{
+		"happy path - one by default": {args: []string{}, expected: []string{"b", "c"}},
+		"happy path - count":          {args: []string{"2"}, expected: []string{"c"}},
+		"sad path - past the end":     {args: []string{"4"}, expectedStatus: 1, expected: []string{"a", "b", "c"}},
+		"sad path - not a number":     {args: []string{"x"}, expectedStatus: 1, expected: []string{"a", "b", "c"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			shell.params = []string{"a", "b", "c"}
+			var stderr bytes.Buffer
+			status := shell.handleShift(tc.args, testIO(io.Discard, &stderr))
+			if status != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, status)
+			}
+			if !slices.Equal(shell.params, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, shell.params)
+			}
+		})
+	}
+}
+
+func TestShell_handleRead(t *testing.T) {
+	tests := map[string]struct {
+		args           []string
+		ifs            string
+		input          string
+		expectedStatus int
+		expected       map[string]string
+		expectedRest   string
+	}{
+		"happy path - one line at a time": {
+			args:         []string{"x"},
+			input:        "first\nsecond\n",
+			expected:     map[string]string{"x": "first"},
+			expectedRest: "second\n",
+		},
+		"happy path - last name gets the rest": {
+			args:     []string{"x", "y"},
+			input:    "  a  b  c  \n",
+			expected: map[string]string{"x": "a", "y": "b  c"},
+		},
+		"happy path - missing fields are empty": {
+			args:     []string{"x", "y", "z"},
+			input:    "a b\n",
+			expected: map[string]string{"x": "a", "y": "b", "z": ""},
+		},
+		"happy path - other IFS characters": {
+			args:     []string{"x", "y", "z"},
+			ifs:      ":",
+			input:    "a::b:c:\n",
+			expected: map[string]string{"x": "a", "y": "", "z": "b:c:"},
+		},
+		"happy path - REPLY keeps whitespace": {
+			input:    "  a b  \n",
+			expected: map[string]string{"REPLY": "  a b  "},
+		},
+		"happy path - backslashes": {
+			args:     []string{"x", "y"},
+			input:    "a\\ b\\\nc d\n",
+			expected: map[string]string{"x": "a bc", "y": "d"},
+		},
+		"happy path - raw": {
+			args:     []string{"-r", "x"},
+			input:    `a\ b` + "\n",
+			expected: map[string]string{"x": `a\ b`},
+		},
+		"happy path - prompt is not shown for a pipe": {
+			args:     []string{"-p", "name? ", "x"},
+			input:    "bob\n",
+			expected: map[string]string{"x": "bob"},
+		},
+		"sad path - end of input without newline": {
+			args:           []string{"x"},
+			input:          "last",
+			expectedStatus: 1,
+			expected:       map[string]string{"x": "last"},
+		},
+		"sad path - invalid name": {
+			args:           []string{"1x"},
+			input:          "a\n",
+			expectedStatus: 1,
+			expectedRest:   "a\n",
+		},
+		"sad path - invalid option": {
+			args:           []string{"-q"},
+			input:          "a\n",
+			expectedStatus: 2,
+			expectedRest:   "a\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			if tc.ifs != "" {
+				shell.setVar("IFS", tc.ifs)
+			}
+			input := strings.NewReader(tc.input)
+			var stderr bytes.Buffer
+			status := shell.handleRead(tc.args, newIOContext(newFdTable(input, io.Discard, &stderr)))
+			if status != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, status)
+			}
+			for name, expected := range tc.expected {
+				if value, _ := shell.getVar(name); value != expected {
+					t.Errorf("expected %s=%q, got %q", name, expected, value)
+				}
+			}
+			if rest, _ := io.ReadAll(input); string(rest) != tc.expectedRest {
+				t.Errorf("expected %q left unread, got %q", tc.expectedRest, rest)
+			}
+			if tc.expectedStatus == 0 && stderr.Len() > 0 {
+				t.Errorf("unexpected error output %q", stderr.String())
+			}
+		})
+	}
+}
+
+func TestShell_handleBreak(t *testing.T) {
+	tests := map[string]struct {
+		name      string
+		args      []string
+		loopDepth int
+		expected  error
+	}{
+		"happy path - break":                   {name: "break", loopDepth: 1, expected: loopControl{Levels: 1}},
+		"happy path - continue outer loop":     {name: "continue", args: []string{"2"}, loopDepth: 3, expected: loopControl{Continue: true, Levels: 2}},
+		"happy path - count beyond the loops":  {name: "break", args: []string{"5"}, loopDepth: 2, expected: loopControl{Levels: 2}},
+		"sad path - outside a loop is ignored": {name: "break"},
+		"sad path - count out of range":        {name: "break", args: []string{"0"}, loopDepth: 1, expected: exitStatus(1)},
+		"sad path - count is not a number":     {name: "continue", args: []string{"x"}, loopDepth: 1, expected: exitStatus(1)},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			shell.loopDepth = tc.loopDepth
+			err := shell.handleBreak(tc.name, tc.args, testIO(io.Discard, io.Discard))
+			if err != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestShell_handleReturn(t *testing.T) {
+	tests := map[string]struct {
+		args       []string
+		inFunction bool
+		lastStatus int
+		expected   error
+	}{
+		"happy path - status":                 {args: []string{"3"}, inFunction: true, expected: funcReturn(3)},
+		"happy path - last status by default": {inFunction: true, lastStatus: 5, expected: funcReturn(5)},
+		"happy path - status wraps":           {args: []string{"257"}, inFunction: true, expected: funcReturn(1)},
+		"sad path - not a number":             {args: []string{"x"}, inFunction: true, expected: funcReturn(2)},
+		"sad path - outside a function":       {args: []string{"1"}, expected: exitStatus(2)},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			shell.lastStatus = tc.lastStatus
+			if tc.inFunction {
+				shell.locals = []map[string]*Variable{{}}
+			}
+			if err := shell.handleReturn(tc.args, testIO(io.Discard, io.Discard)); err != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestShell_handleDeclare(t *testing.T) {
+	tests := map[string]struct {
+		args           []string
+		expectedStatus int
+		expectedOutput string
+		expectedErr    string
+	}{
+		"happy path - print variable": {
+			args:           []string{"-p", "GOSH_V"},
+			expectedOutput: "declare -x GOSH_V=\"1\"\n",
+		},
+		"happy path - list by attribute": {
+			args:           []string{"-r"},
+			expectedOutput: "declare -r GOSH_RO=\"2\"\n",
+		},
+		"happy path - declare array": {
+			args: []string{"-a", "GOSH_A=(x)"},
+		},
+		"sad path - missing variable": {
+			args:           []string{"-p", "GOSH_MISSING"},
+			expectedStatus: 1,
+			expectedErr:    "declare: GOSH_MISSING: not found\n",
+		},
+		"sad path - invalid option": {
+			args:           []string{"-q"},
+			expectedStatus: 2,
+		},
+		"sad path - readonly variable": {
+			args:           []string{"GOSH_RO=3"},
+			expectedStatus: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			shell.vars = map[string]*Variable{}
+			shell.declare("GOSH_V=1", "x", "")
+			shell.declare("GOSH_RO=2", "r", "")
+
+			var stdout, stderr bytes.Buffer
+			status := shell.handleDeclare("declare", tc.args, testIO(&stdout, &stderr))
+			if status != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d (stderr: %q)", tc.expectedStatus, status, stderr.String())
+			}
+			if stdout.String() != tc.expectedOutput {
+				t.Errorf("expected output %q, got %q", tc.expectedOutput, stdout.String())
+			}
+			if tc.expectedErr != "" && stderr.String() != tc.expectedErr {
+				t.Errorf("expected error %q, got %q", tc.expectedErr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestShell_handleExport(t *testing.T) {
+	shell := NewShell()
+	var stdout, stderr bytes.Buffer
+
+	if status := shell.handleExport([]string{"GOSH_E=1"}, testIO(&stdout, &stderr)); status != 0 {
+		t.Fatalf("expected status 0, got %d", status)
+	}
+	if v := shell.vars["GOSH_E"]; v == nil || !v.Exported {
+		t.Fatal("expected GOSH_E to be exported")
+	}
+
+	shell.handleExport([]string{"-n", "GOSH_E"}, testIO(&stdout, &stderr))
+	if shell.vars["GOSH_E"].Exported {
+		t.Error("expected export -n to remove the export attribute")
+	}
+	if value, _ := shell.getVar("GOSH_E"); value != "1" {
+		t.Errorf("expected GOSH_E to keep its value, got %q", value)
+	}
+}
+
+func TestShell_handleUnset(t *testing.T) {
+	shell := NewShell()
+	shell.setVar("GOSH_U", "1")
+	shell.declare("GOSH_RO=1", "r", "")
+	var stderr bytes.Buffer
+
+	if status := shell.handleUnset([]string{"GOSH_U"}, testIO(io.Discard, &stderr)); status != 0 {
+		t.Errorf("expected status 0, got %d", status)
+	}
+	if _, ok := shell.getVar("GOSH_U"); ok {
+		t.Error("expected GOSH_U to be unset")
+	}
+	if status := shell.handleUnset([]string{"GOSH_RO"}, testIO(io.Discard, &stderr)); status != 1 {
+		t.Errorf("expected status 1 for a readonly variable, got %d", status)
+	}
+
+	shell.funcs["GOSH_U"] = &FuncDef{Name: "GOSH_U"}
+	shell.funcs["GOSH_F"] = &FuncDef{Name: "GOSH_F"}
+	shell.setVar("GOSH_F", "1")
+	shell.handleUnset([]string{"GOSH_U", "GOSH_F"}, testIO(io.Discard, &stderr))
+	if _, ok := shell.funcs["GOSH_U"]; ok {
+		t.Error("expected function GOSH_U without a variable to be unset")
+	}
+	if _, ok := shell.funcs["GOSH_F"]; !ok {
+		t.Error("expected only the variable GOSH_F to be unset")
+	}
+	shell.handleUnset([]string{"-f", "GOSH_F"}, testIO(io.Discard, &stderr))
+	if _, ok := shell.funcs["GOSH_F"]; ok {
+		t.Error("expected unset -f to remove function GOSH_F")
+	}
+}
+
+func TestShell_handleEnv(t *testing.T) {
+	shell := NewShell()
+	shell.vars = map[string]*Variable{}
+	shell.declare("GOSH_X=1", "x", "")
+	shell.setVar("GOSH_LOCAL", "2")
+	shell.declare("PATH="+os.Getenv("PATH"), "x", "")
+
+	var buf bytes.Buffer
+	shell.handleEnv([]string{"GOSH_Y=2"}, testIO(&buf, io.Discard))
+	if !strings.Contains(buf.String(), "GOSH_X=1\n") || !strings.Contains(buf.String(), "GOSH_Y=2\n") {
+		t.Errorf("expected exported and added variables, got %q", buf.String())
+	}
+	if strings.Contains(buf.String(), "GOSH_LOCAL") {
+		t.Errorf("expected unexported variable to be hidden, got %q", buf.String())
+	}
+
+	buf.Reset()
+	status := shell.handleEnv([]string{"GOSH_Y=2", "sh", "-c", "echo $GOSH_Y"}, testIO(&buf, io.Discard))
+	if status != 0 || buf.String() != "2\n" {
+		t.Errorf("expected status 0 and %q, got %d and %q", "2\n", status, buf.String())
+	}
+
+	tests := map[string]struct {
+		args           []string
+		expectedStatus int
+		expectedOutput string
+	}{
+		"happy path - ignore the environment":   {args: []string{"-i", "GOSH_Y=2"}, expectedOutput: "GOSH_Y=2\n"},
+		"happy path - dash ignores it too":      {args: []string{"-", "GOSH_Y=2"}, expectedOutput: "GOSH_Y=2\n"},
+		"happy path - unset a variable":         {args: []string{"-u", "GOSH_X", "sh", "-c", "echo x=$GOSH_X"}, expectedOutput: "x=\n"},
+		"happy path - attached unset name":      {args: []string{"-uGOSH_X", "sh", "-c", "echo x=$GOSH_X"}, expectedOutput: "x=\n"},
+		"happy path - program after -i":         {args: []string{"-i", "GOSH_Y=3", "sh", "-c", "echo $GOSH_Y $GOSH_X"}, expectedOutput: "3\n"},
+		"happy path - double dash ends options": {args: []string{"--", "sh", "-c", "echo $GOSH_X"}, expectedOutput: "1\n"},
+		"edge case - no variables reach -i env": {args: []string{"-i", "env"}},
+		"sad path - invalid option":             {args: []string{"-z"}, expectedStatus: 125},
+		"sad path - unset without a name":       {args: []string{"-u"}, expectedStatus: 125},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			status := shell.handleEnv(tc.args, testIO(&buf, io.Discard))
+			if status != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, status)
+			}
+			if buf.String() != tc.expectedOutput {
+				t.Errorf("expected output %q, got %q", tc.expectedOutput, buf.String())
+			}
+		})
+	}
+}
+
+func TestShell_handleShopt(t *testing.T) {
+	tests := map[string]struct {
+		args           []string
+		expectedStatus int
+		expectedOutput string
+	}{
+		"happy path - enable option": {
+			args: []string{"-s", "nullglob"},
+		},
+		"happy path - query enabled option": {
+			args:           []string{"dotglob"},
+			expectedOutput: "dotglob        \ton\n",
+		},
+		"happy path - query disabled option": {
+			args:           []string{"-q", "globstar"},
+			expectedStatus: 1,
+		},
+		"happy path - print enabled options": {
+			args:           []string{"-p", "-s"},
+			expectedOutput: "shopt -s dotglob\n",
+		},
+		"sad path - unknown option name": {
+			args:           []string{"-s", "nonexistent_option"},
+			expectedStatus: 1,
+		},
+		"sad path - invalid flag": {
+			args:           []string{"-Z"},
+			expectedStatus: 2,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			shell.options["dotglob"] = true
+
+			var stdout, stderr bytes.Buffer
+			status := shell.handleShopt(tc.args, testIO(&stdout, &stderr))
+			if status != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d (stderr: %q)", tc.expectedStatus, status, stderr.String())
+			}
+			if stdout.String() != tc.expectedOutput {
+				t.Errorf("expected output %q, got %q", tc.expectedOutput, stdout.String())
+			}
+		})
+	}
+}
+
+func TestShell_handleLet(t *testing.T) {
+	tests := map[string]struct {
+		args           []string
+		expectedStatus int
+		expectedValue  string
+	}{
+		"happy path - assignment": {
+			args:          []string{"V = 6 * 7"},
+			expectedValue: "42",
+		},
+		"happy path - last expression decides": {
+			args:           []string{"V=1", "0"},
+			expectedStatus: 1,
+			expectedValue:  "1",
+		},
+		"sad path - division by zero": {
+			args:           []string{"V=1/0"},
+			expectedStatus: 1,
+		},
+		"sad path - no arguments": {
+			args:           []string{},
+			expectedStatus: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			var stderr bytes.Buffer
+			status := shell.handleLet(tc.args, testIO(io.Discard, &stderr))
+			if status != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d (stderr: %q)", tc.expectedStatus, status, stderr.String())
+			}
+			if value, _ := shell.getVar("V"); value != tc.expectedValue {
+				t.Errorf("expected V=%q, got %q", tc.expectedValue, value)
+			}
+		})
+	}
+}
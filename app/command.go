@@ -1,162 +1,684 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 )
 
+// Command is a single stage of a pipeline: either a simple command built from
+// Words, or a compound command held in Compound. Name and Args hold the words
+// with quotes removed; they are recomputed from Words when the command runs.
+// Assigns holds the NAME=value words that precede the command name; when the
+// command runs they are expanded into Env, the entries added to the
+// command's environment. Redirects are applied in order before it runs.
 type Command struct {
-	Name           string
-	Args           []string
-	RedirectFile   string
-	RedirectStderr bool
-	AppendMode     bool
-	Next           *Command
+	Name      string
+	Args      []string
+	Words     []string
+	Assigns   []string
+	Env       []string
+	Redirects []Redirect
+	Compound  Node
+	Next      *Command
 }
 
-func (s *Shell) parseInput(input string) Command {
-	input = strings.TrimSpace(input)
-	if len(input) == 0 {
-		return Command{}
+// parseInput parses a command line. A line holding a single pipeline is
+// returned as that pipeline's first command; anything else is wrapped in a
+// Command whose Compound is the parsed list.
+func (s *Shell) parseInput(input string) (Command, error) {
+	list, err := parse(input)
+	if err != nil {
+		return Command{}, err
 	}
 
-	var args []string
+	switch {
+	case len(list.Items) == 0:
+		return Command{}, nil
+	case len(list.Items) == 1 && len(list.Items[0].Pipelines) == 1 && !list.Items[0].Pipelines[0].Negate:
+		return *list.Items[0].Pipelines[0].Cmd, nil
+	}
+	return Command{Compound: list}, nil
+}
 
-	if strings.ContainsAny(input, "'\"\\") {
-		args = s.parseQuotedArgs(input)
-	} else {
-		args = strings.Fields(input)
+func (s *Shell) executeCommand(commandLine string) error {
+	cmd, err := s.parseInput(commandLine)
+	if err != nil {
+		s.lastStatus = 2
+		return err
 	}
+	return s.runCommand(cmd, os.Stdin, os.Stdout)
+}
 
-	for i, arg := range args {
-		if i+1 >= len(args) {
-			continue
-		}
-		switch arg {
-		case ">", "1>":
-			redirectFile := args[i+1]
-			args = append(args[:i], args[i+2:]...)
-			return Command{Name: strings.TrimSpace(args[0]), Args: args[1:], RedirectFile: redirectFile}
-		case "2>":
-			redirectFile := args[i+1]
-			args = append(args[:i], args[i+2:]...)
-			return Command{Name: strings.TrimSpace(args[0]), Args: args[1:], RedirectFile: redirectFile, RedirectStderr: true}
-		case ">>", "1>>":
-			redirectFile := args[i+1]
-			args = append(args[:i], args[i+2:]...)
-			return Command{Name: strings.TrimSpace(args[0]), Args: args[1:], RedirectFile: redirectFile, AppendMode: true}
-		case "2>>":
-			redirectFile := args[i+1]
-			args = append(args[:i], args[i+2:]...)
-			return Command{Name: strings.TrimSpace(args[0]), Args: args[1:], RedirectFile: redirectFile, RedirectStderr: true, AppendMode: true}
-		case "|":
-			nextCmd := s.parseInput(strings.Join(args[i+1:], " "))
-			return Command{Name: strings.TrimSpace(args[0]), Args: args[1:i], Next: &nextCmd}
-		}
+// maxFuncDepth bounds the nesting of function calls when FUNCNEST is not
+// set, so that runaway recursion fails instead of exhausting the stack
+const maxFuncDepth = 1000
+
+// exitStatus is the error returned for a command that finished with a
+// non-zero status
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// exitShell is returned when the exit builtin runs in a subshell. It stops
+// the subshell's remaining commands and becomes the subshell's status.
+type exitShell int
+
+func (e exitShell) Error() string {
+	return fmt.Sprintf("exit %d", int(e))
+}
+
+// fatalError is returned when a word of a command fails to expand, as with
+// "${x:?}", an assignment fails or functions nest too deeply. It stops
+// every command up to the shell, which exits with its status; an
+// interactive shell only abandons the command line. A subshell exits too,
+// leaving its parent running.
+type fatalError int
+
+func (e fatalError) Error() string {
+	return fmt.Sprintf("fatal %d", int(e))
+}
+
+// expandError marks an error from expanding a word in a place where other
+// errors, such as failing to open a redirection's file, are not fatal
+type expandError struct{ error }
+
+func (e expandError) Unwrap() error { return e.error }
+
+// loopControl is returned by break and continue. It unwinds the commands
+// up to the enclosing loop, which either ends or, for continue, starts its
+// next iteration; with Levels > 1 the loops around it are unwound too.
+type loopControl struct {
+	Continue bool
+	Levels   int
+}
+
+func (e loopControl) Error() string {
+	if e.Continue {
+		return fmt.Sprintf("continue %d", e.Levels)
 	}
+	return fmt.Sprintf("break %d", e.Levels)
+}
 
-	return Command{Name: strings.TrimSpace(args[0]), Args: args[1:]}
+// funcReturn is returned by the return builtin. It unwinds the commands of
+// the function being run, which then finishes with the status it holds.
+type funcReturn int
+
+func (e funcReturn) Error() string {
+	return fmt.Sprintf("return %d", int(e))
 }
 
-func (s *Shell) executeCommand(commandLine string) error {
-	cmd := s.parseInput(commandLine)
-	return s.runCommand(cmd, os.Stdin, os.Stdout)
+// statusError converts a command's exit status into the error runCommand
+// returns for it
+func statusError(status int) error {
+	if status == 0 {
+		return nil
+	}
+	return exitStatus(status)
+}
+
+// exitCode returns the exit status a runCommand error stands for
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	switch status := err.(type) {
+	case exitStatus:
+		return int(status)
+	case exitShell:
+		return int(status)
+	case loopControl:
+		return 0
+	case funcReturn:
+		return int(status)
+	case fatalError:
+		return int(status)
+	}
+	return 1
+}
+
+// boolStatus maps true to a successful status and false to a failing one
+func boolStatus(ok bool) int {
+	if ok {
+		return 0
+	}
+	return 1
 }
 
+// runCommand runs the pipeline starting at cmd with the given standard input
+// and output, and the shell's standard error
 func (s *Shell) runCommand(cmd Command, stdin io.Reader, stdout io.Writer) error {
-	if cmd.Name == "" {
-		return nil
+	return s.runStages(cmd, newFdTable(stdin, stdout, os.Stderr))
+}
+
+// runStages runs the pipeline starting at cmd and records its status in $?
+// and PIPESTATUS. Every stage of a multi-command pipeline runs concurrently
+// in its own subshell, connected to the next one through an OS pipe.
+func (s *Shell) runStages(cmd Command, fds fdTable) error {
+	if cmd.Next == nil {
+		err := s.runStage(cmd, fds)
+		// A list wrapped by parseInput has already recorded its own status
+		if _, ok := cmd.Compound.(*List); !ok {
+			s.setPipeStatus([]int{exitCode(err)})
+		}
+		return err
 	}
 
-	if cmd.Next != nil {
-		r, w, err := os.Pipe()
+	var stages []Command
+	for c := &cmd; c != nil; c = c.Next {
+		stages = append(stages, *c)
+	}
+
+	statuses := make([]int, len(stages))
+	var wg sync.WaitGroup
+	var prevRead *os.File
+
+	for i, stage := range stages {
+		stageFds := maps.Clone(fds)
+		if prevRead != nil {
+			stageFds[0] = prevRead
+		}
+		var r, w *os.File
+		if i < len(stages)-1 {
+			var err error
+			if r, w, err = os.Pipe(); err != nil {
+				if prevRead != nil {
+					prevRead.Close()
+				}
+				wg.Wait()
+				return err
+			}
+			stageFds[1] = w
+		}
+
+		wg.Add(1)
+		go func(i int, stage Command, sub *Shell, stageFds fdTable, readEnd, writeEnd *os.File) {
+			defer wg.Done()
+			statuses[i] = exitCode(sub.runStage(stage, stageFds))
+			// Closing our ends lets the reader see EOF and a writer still
+			// feeding this stage fail instead of blocking forever
+			if writeEnd != nil {
+				writeEnd.Close()
+			}
+			if readEnd != nil {
+				readEnd.Close()
+			}
+		}(i, stage, s.subshell(), stageFds, prevRead, w)
+
+		prevRead = r
+	}
+	wg.Wait()
+
+	return statusError(s.setPipeStatus(statuses))
+}
+
+// setPipeStatus records the per-stage statuses of a pipeline and returns the
+// pipeline's status: that of the last stage, or with pipefail the last
+// non-zero one
+func (s *Shell) setPipeStatus(statuses []int) int {
+	status := statuses[len(statuses)-1]
+	if s.options["pipefail"] {
+		for _, st := range statuses {
+			if st != 0 {
+				status = st
+			}
+		}
+	}
+	s.pipeStatus = statuses
+	s.lastStatus = status
+	return status
+}
+
+// runStage runs a single command of a pipeline, ignoring cmd.Next, with
+// the descriptors in fds and its own redirections
+func (s *Shell) runStage(cmd Command, fds fdTable) error {
+	// The status of a command without a name is that of its last command
+	// substitution
+	s.substStatus = 0
+	defer s.closeProcSubs(len(s.procSubs))
+	defer s.withFds(fds)()
+
+	// Only a command whose words expand to no fields at all just assigns;
+	// an empty command name is looked up like any other
+	named := cmd.Name != ""
+	if len(cmd.Words) > 0 {
+		fields, err := s.expandWords(cmd.Words)
 		if err != nil {
-			return err
+			fmt.Fprintln(fds.writer(2), err)
+			return fatalError(1)
+		}
+		cmd.Name, cmd.Args = "", nil
+		named = len(fields) > 0
+		if named {
+			cmd.Name, cmd.Args = fields[0], fields[1:]
+		}
+	}
+
+	// Redirections apply to this command only, so they change a copy
+	inherited := fds
+	fds = maps.Clone(fds)
+	s.addProcSubs(fds)
+	closeFiles, err := s.redirect(fds, cmd.Redirects)
+	if err != nil {
+		fmt.Fprintln(inherited.writer(2), err)
+		if errors.As(err, new(expandError)) {
+			return fatalError(1)
+		}
+		return exitStatus(1)
+	}
+	defer closeFiles()
+
+	if cmd.Compound != nil {
+		return s.runNode(cmd.Compound, fds)
+	}
+	ioc := newIOContext(fds)
+
+	if !named {
+		for _, word := range cmd.Assigns {
+			if err := s.assign(word); err != nil {
+				fmt.Fprintln(ioc.stderr, err)
+				return fatalError(1)
+			}
 		}
+		return statusError(s.substStatus)
+	}
 
-		go func() {
-			currentCmd := cmd
-			currentCmd.Next = nil
-			s.runCommand(currentCmd, stdin, w)
-			w.Close()
-		}()
+	if len(cmd.Assigns) > 0 {
+		env, err := s.prefixEnv(cmd.Assigns)
+		if err != nil {
+			fmt.Fprintln(ioc.stderr, err)
+			return fatalError(1)
+		}
+		cmd.Env = env
+	}
 
-		return s.runCommand(*cmd.Next, r, stdout)
+	if fn, ok := s.funcs[cmd.Name]; ok {
+		if len(cmd.Env) > 0 {
+			defer s.withTempVars(cmd.Env)()
+		}
+		return s.callFunction(fn, cmd.Args, fds)
 	}
 
 	if !s.validateCommand(cmd.Name) {
-		fmt.Printf("%s: command not found\n", cmd.Name)
-		return nil
+		fmt.Fprintf(ioc.stderr, "%s: command not found\n", cmd.Name)
+		return exitStatus(127)
+	}
+
+	// Builtins see prefix assignments as variables for their duration
+	if _, ok := builtinCommands[cmd.Name]; ok && len(cmd.Env) > 0 {
+		defer s.withTempVars(cmd.Env)()
 	}
 
+	var status int
 	switch cmd.Name {
 	case "exit":
-		s.handleExit(cmd.Args)
+		status = s.handleExit(cmd.Args, ioc)
+		if s.inSubshell {
+			return exitShell(status)
+		}
 	case "echo":
-		s.handleEcho(cmd, stdout)
+		status = s.handleEcho(cmd.Args, ioc)
 	case "type":
-		s.handleType(cmd.Args, stdout)
+		status = s.handleType(cmd.Args, ioc)
 	case "pwd":
-		s.handlePwd(stdout)
+		status = s.handlePwd(cmd.Args, ioc)
 	case "cd":
-		s.handleCd(cmd.Args, os.Stderr)
+		status = s.handleCd(cmd.Args, ioc)
 	case "history":
-		s.handleHistory(cmd.Args, stdout)
+		status = s.handleHistory(cmd.Args, ioc)
+	case "set":
+		status = s.handleSet(cmd.Args, ioc)
+	case "export":
+		status = s.handleExport(cmd.Args, ioc)
+	case "readonly":
+		status = s.handleReadonly(cmd.Args, ioc)
+	case "declare", "typeset", "local":
+		status = s.handleDeclare(cmd.Name, cmd.Args, ioc)
+	case "unset":
+		status = s.handleUnset(cmd.Args, ioc)
+	case "shopt":
+		status = s.handleShopt(cmd.Args, ioc)
+	case "env":
+		status = s.handleEnv(cmd.Args, ioc)
+	case "shift":
+		status = s.handleShift(cmd.Args, ioc)
+	case "let":
+		status = s.handleLet(cmd.Args, ioc)
+	case "break", "continue":
+		return s.handleBreak(cmd.Name, cmd.Args, ioc)
+	case "return":
+		return s.handleReturn(cmd.Args, ioc)
+	case "read":
+		status = s.handleRead(cmd.Args, ioc)
+	case "test", "[":
+		status = s.handleTest(cmd.Name, cmd.Args, ioc)
 	default:
-		s.handleExternal(cmd, stdin, stdout)
+		status = s.handleExternal(cmd, fds)
 	}
-	return nil
+	// Output to a closed descriptor or a full disk fails the builtin, but
+	// a reader that went away is left to end the pipeline quietly
+	if err := ioc.writeError(); err != nil && !errors.Is(err, syscall.EPIPE) {
+		fmt.Fprintf(ioc.stderr, "%s: write error: %s\n", cmd.Name, strerror(err))
+		return exitStatus(1)
+	}
+	return statusError(status)
 }
 
+// validateCommand reports whether name can be run. A name containing a
+// slash is left for runExternal to try, which reports why it cannot run.
 func (s *Shell) validateCommand(name string) bool {
+	if strings.Contains(name, "/") {
+		return true
+	}
+	if _, ok := s.funcs[name]; ok {
+		return true
+	}
 	if _, ok := builtinCommands[name]; ok {
 		return true
 	}
 	return s.isInPath(name) != ""
 }
 
-func (s *Shell) parseQuotedArgs(input string) []string {
-	var args []string
-	var currentArg strings.Builder
-	inQuotes := false
-	quoteChar := byte(0)
-
-	for i := 0; i < len(input); i++ {
-		c := input[i]
-
-		if c == Backslash && i+1 < len(input) && quoteChar != SingleQuote {
-			nextChar := input[i+1]
-			if quoteChar == DoubleQuote {
-				if nextChar == '\\' || nextChar == '"' {
-					currentArg.WriteByte(nextChar)
-					i++
-				} else {
-					currentArg.WriteByte(c)
-				}
-			} else {
-				currentArg.WriteByte(nextChar)
-				i++
+func (s *Shell) runNode(node Node, fds fdTable) error {
+	defer s.withFds(fds)()
+	switch n := node.(type) {
+	case *List:
+		return s.runList(n, fds)
+	case *Subshell:
+		// exit ends only the subshell, with its status
+		return statusError(exitCode(s.subshell().runList(n.Body, fds)))
+	case *Group:
+		return s.runList(n.Body, fds)
+	case *If:
+		return s.runIf(n, fds)
+	case *Case:
+		return s.runCase(n, fds)
+	case *For:
+		return s.runFor(n, fds)
+	case *ArithFor:
+		return s.runArithFor(n, fds)
+	case *While:
+		return s.runWhile(n, fds)
+	case *ArithCommand:
+		return statusError(s.runArith(n.Expr, fds.writer(2)))
+	case *CondCommand:
+		return s.runCond(n, fds)
+	case *FuncDef:
+		s.funcs[n.Name] = n
+		return nil
+	}
+	return fmt.Errorf("unsupported command %T", node)
+}
+
+func (s *Shell) runList(list *List, fds fdTable) error {
+	var err error
+	for _, item := range list.Items {
+		if err = s.runAndOr(item, fds); unwinding(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// runAndOr runs the first pipeline, then each following one only when the
+// status so far allows it: "&&" needs success, "||" needs failure
+func (s *Shell) runAndOr(andOr *AndOr, fds fdTable) error {
+	err := s.runPipeline(andOr.Pipelines[0], fds)
+	for i, op := range andOr.Ops {
+		if unwinding(err) {
+			return err
+		}
+		if (op == "&&") == (err == nil) {
+			err = s.runPipeline(andOr.Pipelines[i+1], fds)
+		}
+	}
+	return err
+}
+
+// callFunction runs a function with args as its positional parameters and
+// a scope of its own for local variables. Calls nest at most FUNCNEST deep,
+// or maxFuncDepth when that is not set.
+func (s *Shell) callFunction(fn *FuncDef, args []string, fds fdTable) error {
+	limit := maxFuncDepth
+	if value, _ := s.getVar("FUNCNEST"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	if len(s.locals) >= limit {
+		fmt.Fprintf(fds.writer(2), "%s: maximum function nesting level exceeded (%d)\n", fn.Name, limit)
+		return fatalError(1)
+	}
+
+	params := s.params
+	s.params = args
+	s.locals = append(s.locals, map[string]*Variable{})
+	defer func() {
+		s.restoreVars(s.locals[len(s.locals)-1])
+		s.locals = s.locals[:len(s.locals)-1]
+		s.params = params
+	}()
+
+	err := s.runStage(*fn.Body, fds)
+	if status, ok := err.(funcReturn); ok {
+		return statusError(int(status))
+	}
+	return err
+}
+
+// runIf runs the body of the first clause whose condition succeeds, or
+// else the else part. With no part run its status is zero.
+func (s *Shell) runIf(n *If, fds fdTable) error {
+	for _, clause := range n.Clauses {
+		err := s.runList(clause.Cond, fds)
+		if unwinding(err) {
+			return err
+		}
+		if err == nil {
+			return s.runList(clause.Body, fds)
+		}
+	}
+	if n.Else != nil {
+		return s.runList(n.Else, fds)
+	}
+	return nil
+}
+
+// runCase runs a case command. Its status is that of the last body run, or
+// zero when no pattern matches.
+func (s *Shell) runCase(n *Case, fds fdTable) error {
+	word, err := s.expandWord(n.Word)
+	if err != nil {
+		fmt.Fprintln(fds.writer(2), err)
+		return fatalError(1)
+	}
+
+	var status error
+	for i := 0; i < len(n.Items); i++ {
+		matched, err := s.caseMatch(n.Items[i].Patterns, word)
+		if err != nil {
+			fmt.Fprintln(fds.writer(2), err)
+			return exitStatus(1)
+		}
+		if !matched {
+			continue
+		}
+		// ";&" falls through into the next body without testing its patterns
+		for {
+			if status = s.runList(n.Items[i].Body, fds); unwinding(status) {
+				return status
+			}
+			if n.Items[i].Term != ";&" || i == len(n.Items)-1 {
+				break
+			}
+			i++
+		}
+		if n.Items[i].Term != ";;&" {
+			break
+		}
+	}
+	return status
+}
+
+// caseMatch reports whether word matches one of the patterns of a case
+// item, expanding each pattern only until one matches
+func (s *Shell) caseMatch(patterns []string, word string) (bool, error) {
+	for _, raw := range patterns {
+		pattern, err := s.expandPattern(raw)
+		if err != nil {
+			return false, err
+		}
+		if matchPattern(pattern, word) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// runFor runs a for loop. Its status is that of the last command of the
+// body, or zero when the body never ran.
+func (s *Shell) runFor(n *For, fds fdTable) error {
+	values, err := s.expandWords(n.Words)
+	if err != nil {
+		fmt.Fprintln(fds.writer(2), err)
+		return fatalError(1)
+	}
+
+	s.loopDepth++
+	defer func() { s.loopDepth-- }()
+	var status error
+	for _, value := range values {
+		if err := s.setVar(n.Var, value); err != nil {
+			fmt.Fprintln(fds.writer(2), err)
+			return exitStatus(1)
+		}
+		done, err := s.runLoopBody(n.Body, fds)
+		if done {
+			return err
+		}
+		status = err
+	}
+	return status
+}
+
+// runArithFor runs an arithmetic for loop; an error in one of its
+// expressions ends it with status 1
+func (s *Shell) runArithFor(n *ArithFor, fds fdTable) error {
+	eval := func(expr string) (int, error) {
+		v, err := s.arithValue(expr)
+		if err != nil {
+			fmt.Fprintln(fds.writer(2), err)
+		}
+		return v, err
+	}
+
+	s.loopDepth++
+	defer func() { s.loopDepth-- }()
+	if _, err := eval(n.Init); err != nil {
+		return exitStatus(1)
+	}
+	var status error
+	for {
+		if strings.TrimSpace(n.Cond) != "" {
+			v, err := eval(n.Cond)
+			if err != nil {
+				return exitStatus(1)
 			}
-		} else if !inQuotes && (c == SingleQuote || c == DoubleQuote) {
-			inQuotes = true
-			quoteChar = c
-		} else if inQuotes && c == quoteChar {
-			inQuotes = false
-			quoteChar = 0
-		} else if c == ' ' && !inQuotes {
-			if currentArg.Len() > 0 {
-				args = append(args, currentArg.String())
-				currentArg.Reset()
+			if v == 0 {
+				return status
 			}
-		} else {
-			currentArg.WriteByte(c)
 		}
+		done, err := s.runLoopBody(n.Body, fds)
+		if done {
+			return err
+		}
+		status = err
+		if _, err := eval(n.Step); err != nil {
+			return exitStatus(1)
+		}
+	}
+}
+
+// runWhile runs a while or until loop. Its status is that of the last
+// command of the body, or zero when the body never ran.
+func (s *Shell) runWhile(n *While, fds fdTable) error {
+	s.loopDepth++
+	defer func() { s.loopDepth-- }()
+	var status error
+	for {
+		done, err := s.runLoopBody(n.Cond, fds)
+		if done {
+			return err
+		}
+		if (err == nil) == n.Until {
+			return status
+		}
+		if done, err = s.runLoopBody(n.Body, fds); done {
+			return err
+		}
+		status = err
+	}
+}
+
+// runLoopBody runs one iteration of a loop's list and reports whether the
+// loop is done, because of break or of an error that unwinds further, along
+// with the error the loop returns then
+func (s *Shell) runLoopBody(body *List, fds fdTable) (bool, error) {
+	err := s.runList(body, fds)
+	ctl, ok := err.(loopControl)
+	switch {
+	case !ok:
+		return unwinding(err), err
+	case ctl.Levels > 1:
+		ctl.Levels--
+		return true, ctl
+	}
+	return !ctl.Continue, nil
+}
+
+// runArith evaluates an arithmetic command; it succeeds when the result is
+// not zero
+func (s *Shell) runArith(expr string, stderr io.Writer) int {
+	n, err := s.arithValue(expr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
 	}
+	return boolStatus(n != 0)
+}
 
-	if currentArg.Len() > 0 {
-		args = append(args, currentArg.String())
+// unwinding reports whether err stops the commands that follow, rather than
+// being a status for && and || to test
+func unwinding(err error) bool {
+	switch err.(type) {
+	case exitShell, loopControl, funcReturn, fatalError:
+		return true
 	}
+	return false
+}
+
+func (s *Shell) runPipeline(pipeline *Pipeline, fds fdTable) error {
+	err := s.runStages(*pipeline.Cmd, fds)
+	if !pipeline.Negate {
+		return err
+	}
+	if _, ok := err.(exitStatus); ok || err == nil {
+		s.lastStatus = boolStatus(err != nil)
+		return statusError(s.lastStatus)
+	}
+	return err
+}
+
+// parseQuotedArgs splits input into words and expands each of them
+func (s *Shell) parseQuotedArgs(input string) []string {
+	args, _ := s.expandWords(lexWords(input))
 	return args
 }
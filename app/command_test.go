@@ -2,6 +2,9 @@ package main
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -39,35 +42,60 @@ func TestShell_parseInput(t *testing.T) {
 		},
 		"happy path - stdout redirect": {
 			input:    "echo hello > file.txt",
-			expected: Command{Name: "echo", Args: []string{"hello"}, RedirectFile: "file.txt", RedirectStderr: false, AppendMode: false},
+			expected: Command{Name: "echo", Args: []string{"hello"}, Redirects: []Redirect{{Fd: 1, Op: ">", Target: "file.txt"}}},
 		},
 		"happy path - stderr redirect": {
 			input:    "cat file 2> error.txt",
-			expected: Command{Name: "cat", Args: []string{"file"}, RedirectFile: "error.txt", RedirectStderr: true, AppendMode: false},
+			expected: Command{Name: "cat", Args: []string{"file"}, Redirects: []Redirect{{Fd: 2, Op: ">", Target: "error.txt"}}},
 		},
 		"happy path - stdout append": {
 			input:    "echo hello >> file.txt",
-			expected: Command{Name: "echo", Args: []string{"hello"}, RedirectFile: "file.txt", RedirectStderr: false, AppendMode: true},
+			expected: Command{Name: "echo", Args: []string{"hello"}, Redirects: []Redirect{{Fd: 1, Op: ">>", Target: "file.txt"}}},
 		},
 		"happy path - stderr append": {
 			input:    "cat file 2>> error.txt",
-			expected: Command{Name: "cat", Args: []string{"file"}, RedirectFile: "error.txt", RedirectStderr: true, AppendMode: true},
+			expected: Command{Name: "cat", Args: []string{"file"}, Redirects: []Redirect{{Fd: 2, Op: ">>", Target: "error.txt"}}},
 		},
 		"happy path - command with quotes and redirect": {
 			input:    `echo "hello world" > file.txt`,
-			expected: Command{Name: "echo", Args: []string{"hello world"}, RedirectFile: "file.txt", RedirectStderr: false, AppendMode: false},
+			expected: Command{Name: "echo", Args: []string{"hello world"}, Redirects: []Redirect{{Fd: 1, Op: ">", Target: "file.txt"}}},
+		},
+		"happy path - redirect without spaces": {
+			input:    "echo a>b",
+			expected: Command{Name: "echo", Args: []string{"a"}, Redirects: []Redirect{{Fd: 1, Op: ">", Target: "b"}}},
+		},
+		"happy path - quoted pipe is an argument": {
+			input:    `echo "|" a`,
+			expected: Command{Name: "echo", Args: []string{"|", "a"}},
+		},
+		"happy path - redirections in order": {
+			input: "cmd <in 2>&1 3<>rw >&- &>>all",
+			expected: Command{Name: "cmd", Args: []string{}, Redirects: []Redirect{
+				{Fd: 0, Op: "<", Target: "in"}, {Fd: 2, Op: ">&", Target: "1"}, {Fd: 3, Op: "<>", Target: "rw"},
+				{Fd: 1, Op: ">&", Target: "-"}, {Fd: 1, Op: "&>>", Target: "all"},
+			}},
+		},
+		"happy path - redirect before command name": {
+			input:    "> out.txt echo hi",
+			expected: Command{Name: "echo", Args: []string{"hi"}, Redirects: []Redirect{{Fd: 1, Op: ">", Target: "out.txt"}}},
 		},
 	}
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			result := shell.parseInput(tc.input)
+			result, err := shell.parseInput(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
 			if result.Name != tc.expected.Name {
 				t.Errorf("expected Name %q, got %q", tc.expected.Name, result.Name)
 			}
 			if len(result.Args) != len(tc.expected.Args) {
 				t.Errorf("expected %d args, got %d", len(tc.expected.Args), len(result.Args))
 			}
+			if !slices.Equal(result.Redirects, tc.expected.Redirects) {
+				t.Errorf("expected Redirects %v, got %v", tc.expected.Redirects, result.Redirects)
+			}
 			for i, arg := range result.Args {
 				if i < len(tc.expected.Args) && arg != tc.expected.Args[i] {
 					t.Errorf("expected arg[%d] %q, got %q", i, tc.expected.Args[i], arg)
@@ -77,6 +105,25 @@ func TestShell_parseInput(t *testing.T) {
 	}
 }
 
+func TestShell_parseInput_List(t *testing.T) {
+	shell := NewShell()
+
+	result, err := shell.parseInput("echo a; ls | wc && echo b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	list, ok := result.Compound.(*List)
+	if !ok {
+		t.Fatalf("expected Compound to be *List, got %T", result.Compound)
+	}
+	if len(list.Items) != 2 {
+		t.Fatalf("expected 2 list items, got %d", len(list.Items))
+	}
+	if first := list.Items[1].Pipelines[0].Cmd; first.Name != "ls" || first.Next == nil || first.Next.Name != "wc" {
+		t.Errorf("expected pipeline ls | wc, got %+v", first)
+	}
+}
+
 func TestShell_validateCommand(t *testing.T) {
 	shell := NewShell()
 
@@ -112,6 +159,10 @@ func TestShell_validateCommand(t *testing.T) {
 			command:  "exit",
 			expected: true,
 		},
+		"edge case - path is left to run": {
+			command:  "./nonexistent_command_xyz",
+			expected: true,
+		},
 	}
 
 	for name, tc := range tests {
@@ -148,7 +199,7 @@ func TestShell_parseQuotedArgs(t *testing.T) {
 			expected: []string{"echo", `hello "world"`},
 		},
 		"happy path - backslash in single quotes": {
-			input:    `echo 'hello\'world'`,
+			input:    `echo 'hello\'world`,
 			expected: []string{"echo", `hello\world`},
 		},
 		"happy path - escaped space": {
@@ -165,7 +216,7 @@ func TestShell_parseQuotedArgs(t *testing.T) {
 		},
 		"happy path - empty quotes": {
 			input:    `echo ""`,
-			expected: []string{"echo"},
+			expected: []string{"echo", ""},
 		},
 		"edge case - multiple spaces": {
 			input:    `echo   hello    world`,
@@ -242,6 +293,17 @@ func TestShell_parseInput_Pipes(t *testing.T) {
 				},
 			},
 		},
+		"happy path - pipe without spaces": {
+			input: "ls|wc",
+			expected: Command{
+				Name: "ls",
+				Args: []string{},
+				Next: &Command{
+					Name: "wc",
+					Args: []string{},
+				},
+			},
+		},
 		"happy path - pipe with args": {
 			input: "ls -la | grep main",
 			expected: Command{
@@ -257,7 +319,10 @@ func TestShell_parseInput_Pipes(t *testing.T) {
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			result := shell.parseInput(tc.input)
+			result, err := shell.parseInput(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
 
 			// Check first command
 			if result.Name != tc.expected.Name {
@@ -326,3 +391,493 @@ func TestShell_runCommand_Pipes(t *testing.T) {
 		})
 	}
 }
+
+func TestShell_runCommand_Lists(t *testing.T) {
+	shell := NewShell()
+
+	tests := map[string]struct {
+		input          string
+		expected       string
+		expectedStatus int
+	}{
+		"happy path - semicolon runs both": {
+			input:    "echo a; echo b",
+			expected: "a\nb\n",
+		},
+		"happy path - and after success": {
+			input:    "true && echo ran",
+			expected: "ran\n",
+		},
+		"happy path - and after failure": {
+			input:          "false && echo ran",
+			expected:       "",
+			expectedStatus: 1,
+		},
+		"happy path - or after failure": {
+			input:    "false || echo fallback",
+			expected: "fallback\n",
+		},
+		"happy path - chained and or": {
+			input:    "false && echo run || echo failed",
+			expected: "failed\n",
+		},
+		"happy path - prefix assignment reaches the child only": {
+			input:    "GOSH_P=1 sh -c 'echo $GOSH_P'; echo \"[$GOSH_P]\"",
+			expected: "1\n[]\n",
+		},
+		"happy path - exported variable reaches the child": {
+			input:    "export GOSH_Q=2; sh -c 'echo $GOSH_Q'",
+			expected: "2\n",
+		},
+		"happy path - array elements and length": {
+			input:    "a=(x y z); echo ${a[1]} ${#a[@]} ${a[@]}",
+			expected: "y 3 x y z\n",
+		},
+		"happy path - command substitution": {
+			input:    `echo "built at $(echo now)" ` + "`echo legacy`",
+			expected: "built at now legacy\n",
+		},
+		"happy path - substitution runs in a subshell": {
+			input:    "v=1; echo $(v=2; echo $v) $v",
+			expected: "2 1\n",
+		},
+		"happy path - exit leaves only the subshell": {
+			input:    "echo $(exit 3; echo no)$?",
+			expected: "3\n",
+		},
+		"happy path - subshell keeps variables": {
+			input:    "x=1; (x=2; echo $x); echo $x",
+			expected: "2\n1\n",
+		},
+		"happy path - subshell keeps working directory": {
+			input:    "cd /; (cd /tmp; pwd); pwd",
+			expected: "/tmp\n/\n",
+		},
+		"happy path - subshell exit status": {
+			input:          "(exit 3)",
+			expectedStatus: 3,
+		},
+		"happy path - group runs in the current shell": {
+			input:    "{ x=5; echo a; }; echo $x",
+			expected: "a\n5\n",
+		},
+		"happy path - group redirection applies to every command": {
+			input:    "{ echo a; sh -c 'echo b >&2'; } 2>&1 | tr a-z A-Z",
+			expected: "A\nB\n",
+		},
+		"happy path - if runs the then part": {
+			input:    "if true; then echo yes; else echo no; fi",
+			expected: "yes\n",
+		},
+		"happy path - if runs the else part": {
+			input:    "if false; then echo yes; else echo no; fi",
+			expected: "no\n",
+		},
+		"happy path - first elif that succeeds": {
+			input:    "n=2; if (( n == 1 )); then echo one; elif (( n == 2 )); then echo two; elif true; then echo any; fi",
+			expected: "two\n",
+		},
+		"happy path - if condition is a list": {
+			input:    "if true; false || true; then echo yes; fi",
+			expected: "yes\n",
+		},
+		"happy path - if without a part run succeeds": {
+			input:    "if false; then echo yes; fi",
+			expected: "",
+		},
+		"happy path - if status is that of its part": {
+			input:          "if true; then false; fi",
+			expected:       "",
+			expectedStatus: 1,
+		},
+		"happy path - if output piped": {
+			input:    "if true; then echo a; echo b; fi | tr a-z A-Z",
+			expected: "A\nB\n",
+		},
+		"happy path - if input redirected": {
+			input:    "if true; then cat; fi <<< in",
+			expected: "in\n",
+		},
+		"happy path - nested if": {
+			input:    "if true; then if false; then echo a; else echo b; fi; fi",
+			expected: "b\n",
+		},
+		"happy path - for loop": {
+			input:    "for x in a 'b c' d; do echo \"<$x>\"; done",
+			expected: "<a>\n<b c>\n<d>\n",
+		},
+		"happy path - for loop over positional parameters": {
+			input:    "set -- p q; for x; do echo $x; done",
+			expected: "p\nq\n",
+		},
+		"happy path - arithmetic for loop": {
+			input:    "for ((i=0; i<3; i++)); do echo $i; done; echo end $i",
+			expected: "0\n1\n2\nend 3\n",
+		},
+		"happy path - while loop": {
+			input:    "n=0; while (( n < 3 )); do n=$((n+1)); echo $n; done",
+			expected: "1\n2\n3\n",
+		},
+		"happy path - until loop": {
+			input:    "n=0; until (( n == 2 )); do ((n++)); done; echo $n",
+			expected: "2\n",
+		},
+		"happy path - break leaves the loop": {
+			input:    "for i in 1 2 3; do if (( i == 2 )); then break; fi; echo $i; done; echo $?",
+			expected: "1\n0\n",
+		},
+		"happy path - continue skips the rest of the body": {
+			input:    "for i in 1 2 3; do (( i == 2 )) && continue; echo $i; done",
+			expected: "1\n3\n",
+		},
+		"happy path - break unwinds nested loops": {
+			input:    "for i in 1 2; do for j in a b; do break 2; done; echo no; done; echo $i$j",
+			expected: "1a\n",
+		},
+		"happy path - continue resumes an outer loop": {
+			input:    "for i in 1 2; do for j in a b; do echo $i$j; continue 2; done; done",
+			expected: "1a\n2a\n",
+		},
+		"happy path - break inside a group": {
+			input:    "while true; do { echo once; break; }; done",
+			expected: "once\n",
+		},
+		"happy path - break does not leave a subshell": {
+			input:    "for i in 1 2; do (break 2>/dev/null); echo $i; done",
+			expected: "1\n2\n",
+		},
+		"happy path - piped into a loop": {
+			input:    "printf 'a 1\\nb 2\\n' | while read name n; do echo $n$name; done",
+			expected: "1a\n2b\n",
+		},
+		"happy path - loop output piped": {
+			input:    "for x in a b; do echo $x; done | tr a-z A-Z",
+			expected: "A\nB\n",
+		},
+		"happy path - loop reads redirected input": {
+			input:    "while read l; do echo \"[$l]\"; done <<< \"one\"",
+			expected: "[one]\n",
+		},
+		"happy path - loop status is the body's": {
+			input:          "for x in a; do false; done",
+			expected:       "",
+			expectedStatus: 1,
+		},
+		"happy path - loop that never runs succeeds": {
+			input:    "false; while false; do echo; done",
+			expected: "",
+		},
+		"happy path - case runs the first match": {
+			input:    "case banana in a*|b*) echo ab;; *) echo any;; esac",
+			expected: "ab\n",
+		},
+		"happy path - case quoted pattern is literal": {
+			input:    "case '*' in \"*\") echo star;; *) echo any;; esac",
+			expected: "star\n",
+		},
+		"happy path - case pattern from a variable": {
+			input:    "p='x*'; case xyz in $p) echo glob;; esac",
+			expected: "glob\n",
+		},
+		"happy path - case falls through": {
+			input:    "case a in a) echo a;& b) echo b;; c) echo c;; esac",
+			expected: "a\nb\n",
+		},
+		"happy path - case goes on testing": {
+			input:    "case ab in a*) echo a;;& c*) echo c;;& *b) echo b;; *) echo any;; esac",
+			expected: "a\nb\n",
+		},
+		"happy path - case extended pattern": {
+			input:    "(shopt -s extglob; case main.go in @(*.c|*.go)) echo ext;; esac)",
+			expected: "ext\n",
+		},
+		"happy path - case inside command substitution": {
+			input:    "echo $(case x in x) echo sub;; esac)",
+			expected: "sub\n",
+		},
+		"happy path - case without a match succeeds": {
+			input:    "false; case z in a) echo a;; esac",
+			expected: "",
+		},
+		"happy path - case status is the body's": {
+			input:          "case z in z) false;; esac",
+			expected:       "",
+			expectedStatus: 1,
+		},
+		"happy path - case output piped": {
+			input:    "case b in [abc]) echo bracket;; esac | tr a-z A-Z",
+			expected: "BRACKET\n",
+		},
+		"happy path - function call": {
+			input:    "greet() { echo \"hi $1 ($#)\"; }; greet you there",
+			expected: "hi you (2)\n",
+		},
+		"happy path - function keyword": {
+			input:    "function twice { echo $1$1; }; twice ab",
+			expected: "abab\n",
+		},
+		"happy path - function positional parameters are restored": {
+			input:    "set -- a b; f() { shift; echo $#; }; f x y z; echo $# $1",
+			expected: "2\n2 a\n",
+		},
+		"happy path - function sees its caller's locals": {
+			input:    "fn_x=global; f() { local fn_x=f; g; echo $fn_x; }; g() { echo $fn_x; fn_x=g; }; f; echo $fn_x",
+			expected: "f\ng\nglobal\n",
+		},
+		"happy path - declare in a function is local": {
+			input:    "f() { declare fn_decl=1; echo $fn_decl; }; f; echo \"[$fn_decl]\"",
+			expected: "1\n[]\n",
+		},
+		"happy path - function changes globals": {
+			input:    "f() { fn_global=1; }; f; echo $fn_global",
+			expected: "1\n",
+		},
+		"happy path - return status": {
+			input:    "f() { return 3; echo no; }; f; echo $?",
+			expected: "3\n",
+		},
+		"happy path - return leaves loops": {
+			input:    "f() { for i in 1 2 3; do (( i == 2 )) && return $i; echo $i; done; }; f; echo $?",
+			expected: "1\n2\n",
+		},
+		"happy path - return in a subshell ends it": {
+			input:    "f() { (return 4); echo $?; }; f",
+			expected: "4\n",
+		},
+		"happy path - recursion": {
+			input:    "fact() { if (( $1 <= 1 )); then echo 1; else echo $(( $1 * $(fact $(( $1 - 1 ))) )); fi; }; fact 5",
+			expected: "120\n",
+		},
+		"happy path - FUNCNEST limits recursion": {
+			input:    "f() { echo $1; f $(( $1 + 1 )); echo back; }; (FUNCNEST=3 f 1) 2>/dev/null; echo $?",
+			expected: "1\n2\n3\n1\n",
+		},
+		"happy path - function redirection applies to each call": {
+			input:    "f() { echo $1; } >&2; f x 2>&1 | tr a-z A-Z",
+			expected: "X\n",
+		},
+		"happy path - function in a pipeline": {
+			input:    "up() { tr a-z A-Z; }; echo piped | up",
+			expected: "PIPED\n",
+		},
+		"happy path - prefix assignment lasts for the call": {
+			input:    "f() { echo $FN_V; }; FN_V=2 f; echo \"[$FN_V]\"",
+			expected: "2\n[]\n",
+		},
+		"happy path - function defined in a subshell does not last": {
+			input:    "(gosh_sub() { echo; }); type gosh_sub >/dev/null 2>&1 || echo none",
+			expected: "none\n",
+		},
+		"happy path - function hides a command": {
+			input:    "ls() { echo mine; }; ls; unset -f ls",
+			expected: "mine\n",
+		},
+		"happy path - pipeline stages run in subshells": {
+			input:    "x=1; echo a | x=2; echo $x",
+			expected: "1\n",
+		},
+		"happy path - assignment takes substitution status": {
+			input:          "v=$(false)",
+			expected:       "",
+			expectedStatus: 1,
+		},
+		"happy path - arithmetic command true": {
+			input:    "n=3; (( n > 2 )) && echo big",
+			expected: "big\n",
+		},
+		"happy path - arithmetic command false": {
+			input:          "(( 0 ))",
+			expected:       "",
+			expectedStatus: 1,
+		},
+		"happy path - arithmetic command assigns": {
+			input:    "n=1; ((n += 2)); echo $n",
+			expected: "3\n",
+		},
+		"happy path - builtin status": {
+			input:    "cd /nonexistent_directory_xyz 2>/dev/null || echo failed",
+			expected: "failed\n",
+		},
+		"happy path - negated pipeline": {
+			input:    "! false && echo negated",
+			expected: "negated\n",
+		},
+		"happy path - pipeline status is last command": {
+			input:          "true | false",
+			expected:       "",
+			expectedStatus: 1,
+		},
+		"happy path - assignment then expansion": {
+			input:    "GREETING=hi; echo $GREETING \"${GREETING}!\" '$GREETING'",
+			expected: "hi hi! $GREETING\n",
+		},
+		"happy path - command substitution reads the pipe": {
+			input:    `echo inner | { subst_in=$(cat); echo "got $subst_in"; }`,
+			expected: "got inner\n",
+		},
+		"happy path - command substitution inherits stderr": {
+			input:    "{ subst_err=$(echo oops >&2); } 2>&1",
+			expected: "oops\n",
+		},
+		"sad path - command not found": {
+			input:          "nonexistent_command_xyz",
+			expected:       "",
+			expectedStatus: 127,
+		},
+		"sad path - empty command name": {
+			input:          `empty_name=""; "$empty_name" foo`,
+			expected:       "",
+			expectedStatus: 127,
+		},
+		"edge case - command that expands to nothing only assigns": {
+			input:    `empty_name=""; nothing_assigned=1 $empty_name; echo $nothing_assigned`,
+			expected: "1\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmd, err := shell.parseInput(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			err = shell.runCommand(cmd, strings.NewReader(""), &buf)
+			status := 0
+			if code, ok := err.(exitStatus); ok {
+				status = int(code)
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if status != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, status)
+			}
+			if buf.String() != tc.expected {
+				t.Errorf("expected output %q, got %q", tc.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestShell_runCommand_NotRunnable(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "noexec"), []byte("#!/bin/sh\n"), 0644)
+	os.Mkdir(filepath.Join(dir, "subdir"), 0755)
+
+	tests := map[string]struct {
+		input          string
+		expected       string
+		expectedStatus int
+	}{
+		"sad path - file without execute permission": {
+			input:          "./noexec 2>&1",
+			expected:       "./noexec: Permission denied\n",
+			expectedStatus: 126,
+		},
+		"sad path - directory": {
+			input:          "./subdir 2>&1",
+			expected:       "./subdir: Is a directory\n",
+			expectedStatus: 126,
+		},
+		"sad path - missing file": {
+			input:          "./missing 2>&1",
+			expected:       "./missing: No such file or directory\n",
+			expectedStatus: 127,
+		},
+		"sad path - name without a slash is looked up": {
+			input:          "noexec 2>&1",
+			expected:       "noexec: command not found\n",
+			expectedStatus: 127,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			shell.cwd = dir
+
+			cmd, err := shell.parseInput(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			var buf bytes.Buffer
+			shell.runCommand(cmd, strings.NewReader(""), &buf)
+
+			if shell.lastStatus != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, shell.lastStatus)
+			}
+			if buf.String() != tc.expected {
+				t.Errorf("expected output %q, got %q", tc.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestShell_runCommand_Status(t *testing.T) {
+	tests := map[string]struct {
+		input              string
+		pipefail           bool
+		expectedStatus     int
+		expectedPipeStatus []int
+	}{
+		"happy path - external failure": {
+			input:              "ls /nonexistent_directory_xyz",
+			expectedStatus:     2,
+			expectedPipeStatus: []int{2},
+		},
+		"happy path - pipeline statuses": {
+			input:              "false | true | sh -c 'exit 3'",
+			expectedStatus:     3,
+			expectedPipeStatus: []int{1, 0, 3},
+		},
+		"happy path - last stage wins without pipefail": {
+			input:              "false | true",
+			expectedStatus:     0,
+			expectedPipeStatus: []int{1, 0},
+		},
+		"happy path - pipefail reports rightmost failure": {
+			input:              "sh -c 'exit 4' | false | true",
+			pipefail:           true,
+			expectedStatus:     1,
+			expectedPipeStatus: []int{4, 1, 0},
+		},
+		"happy path - killed by signal": {
+			input:              "sh -c 'kill -9 $$'",
+			expectedStatus:     137,
+			expectedPipeStatus: []int{137},
+		},
+		"happy path - negation sets last status": {
+			input:              "! true",
+			expectedStatus:     1,
+			expectedPipeStatus: []int{0},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			shell.options["pipefail"] = tc.pipefail
+
+			cmd, err := shell.parseInput(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			var buf bytes.Buffer
+			shell.runCommand(cmd, strings.NewReader(""), &buf)
+
+			if shell.lastStatus != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, shell.lastStatus)
+			}
+			if len(shell.pipeStatus) != len(tc.expectedPipeStatus) {
+				t.Fatalf("expected PIPESTATUS %v, got %v", tc.expectedPipeStatus, shell.pipeStatus)
+			}
+			for i := range shell.pipeStatus {
+				if shell.pipeStatus[i] != tc.expectedPipeStatus[i] {
+					t.Errorf("expected PIPESTATUS %v, got %v", tc.expectedPipeStatus, shell.pipeStatus)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/chzyer/readline"
+)
+
+// unaryTests are the operators that test a single operand, in test and
+// in [[ ]]
+var unaryTests = map[string]bool{
+	"-a": true, "-b": true, "-c": true, "-d": true, "-e": true, "-f": true, "-g": true,
+	"-h": true, "-k": true, "-p": true, "-r": true, "-s": true, "-t": true, "-u": true,
+	"-w": true, "-x": true, "-G": true, "-L": true, "-O": true, "-S": true,
+	"-n": true, "-z": true, "-o": true, "-v": true,
+}
+
+// binaryTests are the operators that compare two operands. '<' and '>'
+// are also binary tests, but in [[ ]] they are lexed as operators.
+var binaryTests = map[string]bool{
+	"=": true, "==": true, "!=": true, "=~": true, "<": true, ">": true,
+	"-eq": true, "-ne": true, "-lt": true, "-le": true, "-gt": true, "-ge": true,
+	"-nt": true, "-ot": true, "-ef": true,
+}
+
+// handleTest runs test, or "[" which also needs a closing "]". It succeeds
+// when the expression is true, fails when it is false and returns 2 when it
+// is malformed.
+func (s *Shell) handleTest(name string, args []string, ioc *ioContext) int {
+	if name == "[" {
+		if len(args) == 0 || args[len(args)-1] != "]" {
+			fmt.Fprintln(ioc.stderr, "[: missing `]'")
+			return 2
+		}
+		args = args[:len(args)-1]
+	}
+	t := &testParser{s: s, fds: ioc.fds, args: args}
+	ok, err := t.eval()
+	if err != nil {
+		fmt.Fprintf(ioc.stderr, "%s: %s\n", name, err)
+		return 2
+	}
+	return boolStatus(ok)
+}
+
+// testParser evaluates the arguments of test. Up to four arguments are
+// read by the POSIX rules, which decide by their number; longer
+// expressions are parsed with "-o" binding looser than "-a", then "!".
+type testParser struct {
+	s    *Shell
+	fds  fdTable
+	args []string
+	pos  int
+}
+
+func (t *testParser) eval() (bool, error) {
+	args := t.args
+	switch len(args) {
+	case 0:
+		return false, nil
+	case 1:
+		return args[0] != "", nil
+	case 2:
+		if args[0] == "!" {
+			return args[1] == "", nil
+		}
+		if unaryTests[args[0]] {
+			return t.s.unaryTest(args[0], args[1], t.fds)
+		}
+		return false, fmt.Errorf("%s: unary operator expected", args[0])
+	case 3:
+		if testBinary(args[1]) {
+			return t.binary(args[0], args[1], args[2])
+		}
+		if args[0] == "!" {
+			ok, err := (&testParser{s: t.s, fds: t.fds, args: args[1:]}).eval()
+			return !ok, err
+		}
+		if args[0] == "(" && args[2] == ")" {
+			return args[1] != "", nil
+		}
+		return false, fmt.Errorf("%s: binary operator expected", args[1])
+	case 4:
+		if args[0] == "!" {
+			ok, err := (&testParser{s: t.s, fds: t.fds, args: args[1:]}).eval()
+			return !ok, err
+		}
+		if args[0] == "(" && args[3] == ")" {
+			return (&testParser{s: t.s, fds: t.fds, args: args[1:3]}).eval()
+		}
+	}
+
+	ok, err := t.or()
+	if err == nil && t.pos < len(t.args) {
+		err = fmt.Errorf("too many arguments")
+	}
+	return ok, err
+}
+
+// testBinary reports whether op is a binary operator of test, where "-a"
+// and "-o" join two expressions
+func testBinary(op string) bool {
+	return binaryTests[op] && op != "=~" || op == "-a" || op == "-o"
+}
+
+func (t *testParser) peek() string {
+	if t.pos < len(t.args) {
+		return t.args[t.pos]
+	}
+	return ""
+}
+
+func (t *testParser) or() (bool, error) {
+	x, err := t.and()
+	for err == nil && t.peek() == "-o" {
+		t.pos++
+		var y bool
+		y, err = t.and()
+		x = x || y
+	}
+	return x, err
+}
+
+func (t *testParser) and() (bool, error) {
+	x, err := t.not()
+	for err == nil && t.peek() == "-a" {
+		t.pos++
+		var y bool
+		y, err = t.not()
+		x = x && y
+	}
+	return x, err
+}
+
+func (t *testParser) not() (bool, error) {
+	if t.peek() == "!" {
+		t.pos++
+		ok, err := t.not()
+		return !ok, err
+	}
+	return t.primary()
+}
+
+func (t *testParser) primary() (bool, error) {
+	if t.pos >= len(t.args) {
+		return false, fmt.Errorf("argument expected")
+	}
+	arg := t.args[t.pos]
+	t.pos++
+
+	if arg == "(" {
+		ok, err := t.or()
+		if err != nil {
+			return false, err
+		}
+		if t.peek() != ")" {
+			return false, fmt.Errorf("`)' expected")
+		}
+		t.pos++
+		return ok, nil
+	}
+	if unaryTests[arg] && t.pos < len(t.args) {
+		operand := t.args[t.pos]
+		t.pos++
+		return t.s.unaryTest(arg, operand, t.fds)
+	}
+	if op := t.peek(); testBinary(op) && op != "-a" && op != "-o" && t.pos+1 < len(t.args) {
+		t.pos += 2
+		return t.binary(arg, op, t.args[t.pos-1])
+	}
+	return arg != "", nil
+}
+
+// binary evaluates a binary operator of test, whose integer comparisons
+// take decimal integers
+func (t *testParser) binary(x, op, y string) (bool, error) {
+	switch op {
+	case "-a":
+		return x != "" && y != "", nil
+	case "-o":
+		return x != "" || y != "", nil
+	case "=", "==":
+		return x == y, nil
+	case "!=":
+		return x != y, nil
+	}
+	if intComparison(op) {
+		a, err := testInt(x)
+		if err != nil {
+			return false, err
+		}
+		b, err := testInt(y)
+		if err != nil {
+			return false, err
+		}
+		return compareInts(op, a, b), nil
+	}
+	return t.s.binaryTest(op, x, y), nil
+}
+
+func testInt(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%s: integer expression expected", s)
+	}
+	return n, nil
+}
+
+func intComparison(op string) bool {
+	switch op {
+	case "-eq", "-ne", "-lt", "-le", "-gt", "-ge":
+		return true
+	}
+	return false
+}
+
+func compareInts(op string, a, b int) bool {
+	switch op {
+	case "-eq":
+		return a == b
+	case "-ne":
+		return a != b
+	case "-lt":
+		return a < b
+	case "-le":
+		return a <= b
+	case "-gt":
+		return a > b
+	}
+	return a >= b
+}
+
+// unaryTest evaluates a unary operator on an expanded operand: the string
+// tests -n and -z, -v for a set variable, -o for an enabled option, -t for a
+// terminal descriptor, and otherwise a test of the file the operand names
+func (s *Shell) unaryTest(op, arg string, fds fdTable) (bool, error) {
+	switch op {
+	case "-n":
+		return arg != "", nil
+	case "-z":
+		return arg == "", nil
+	case "-v":
+		_, ok := s.getVar(arg)
+		return ok, nil
+	case "-o":
+		return s.options[arg], nil
+	case "-t":
+		fd, err := testInt(arg)
+		if err != nil {
+			return false, err
+		}
+		f, ok := fds[fd].(*os.File)
+		return ok && readline.IsTerminal(int(f.Fd())), nil
+	}
+	if arg == "" {
+		return false, nil
+	}
+
+	path := s.resolvePath(arg)
+	if op == "-h" || op == "-L" {
+		info, err := os.Lstat(path)
+		return err == nil && info.Mode()&os.ModeSymlink != 0, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, nil
+	}
+	mode := info.Mode()
+	switch op {
+	case "-a", "-e":
+		return true, nil
+	case "-f":
+		return mode.IsRegular(), nil
+	case "-d":
+		return mode.IsDir(), nil
+	case "-s":
+		return info.Size() > 0, nil
+	case "-b":
+		return mode&os.ModeDevice != 0 && mode&os.ModeCharDevice == 0, nil
+	case "-c":
+		return mode&os.ModeCharDevice != 0, nil
+	case "-p":
+		return mode&os.ModeNamedPipe != 0, nil
+	case "-S":
+		return mode&os.ModeSocket != 0, nil
+	case "-g":
+		return mode&os.ModeSetgid != 0, nil
+	case "-u":
+		return mode&os.ModeSetuid != 0, nil
+	case "-k":
+		return mode&os.ModeSticky != 0, nil
+	case "-r":
+		return syscall.Access(path, 4) == nil, nil
+	case "-w":
+		return syscall.Access(path, 2) == nil, nil
+	case "-x":
+		return syscall.Access(path, 1) == nil, nil
+	case "-O", "-G":
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return false, nil
+		}
+		if op == "-O" {
+			return int(st.Uid) == os.Geteuid(), nil
+		}
+		return int(st.Gid) == os.Getegid(), nil
+	}
+	return false, fmt.Errorf("%s: unary operator expected", op)
+}
+
+// binaryTest evaluates the string ordering and file comparison operators,
+// which test and [[ ]] share
+func (s *Shell) binaryTest(op, x, y string) bool {
+	switch op {
+	case "<":
+		return x < y
+	case ">":
+		return x > y
+	}
+
+	xi, xerr := os.Stat(s.resolvePath(x))
+	yi, yerr := os.Stat(s.resolvePath(y))
+	switch op {
+	case "-nt":
+		return xerr == nil && (yerr != nil || xi.ModTime().After(yi.ModTime()))
+	case "-ot":
+		return yerr == nil && (xerr != nil || xi.ModTime().Before(yi.ModTime()))
+	case "-ef":
+		return xerr == nil && yerr == nil && os.SameFile(xi, yi)
+	}
+	return false
+}
+
+// errBadRegex reports a regular expression for "=~" that does not compile
+var errBadRegex = errors.New("invalid regular expression")
+
+// runCond runs a [[ ]] command. Its status is 0 when the expression is
+// true, and 1 when it is false or fails to evaluate; an invalid regular
+// expression makes it 2. An operand that fails to expand is fatal.
+func (s *Shell) runCond(n *CondCommand, fds fdTable) error {
+	ok, err := s.evalCond(n.Expr, fds)
+	if err != nil {
+		fmt.Fprintln(fds.writer(2), err)
+		switch {
+		case errors.As(err, new(expandError)):
+			return fatalError(1)
+		case errors.Is(err, errBadRegex):
+			return exitStatus(2)
+		}
+		return exitStatus(1)
+	}
+	return statusError(boolStatus(ok))
+}
+
+// evalCond evaluates a [[ ]] expression. Operands are expanded without
+// field splitting or pathname expansion. The right side of "==" and "!=" is
+// a pattern and that of "=~" a regular expression, in which quoted parts
+// match literally; integer comparisons take arithmetic expressions.
+func (s *Shell) evalCond(e *CondExpr, fds fdTable) (bool, error) {
+	switch e.Op {
+	case "&&", "||":
+		x, err := s.evalCond(e.X, fds)
+		if err != nil || x == (e.Op == "||") {
+			return x, err
+		}
+		return s.evalCond(e.Y, fds)
+	case "!":
+		x, err := s.evalCond(e.X, fds)
+		return !x, err
+	}
+
+	x, err := s.expandWord(e.Args[0])
+	if err != nil {
+		return false, expandError{err}
+	}
+	switch {
+	case e.Op == "":
+		return x != "", nil
+	case len(e.Args) == 1:
+		return s.unaryTest(e.Op, x, fds)
+	case e.Op == "=~":
+		return s.matchRegex(x, e.Args[1])
+	}
+
+	if e.Op == "==" || e.Op == "=" || e.Op == "!=" {
+		// Extended patterns are always on here, as in bash
+		frags, err := s.expandFragments(e.Args[1], false, false)
+		if err != nil {
+			return false, expandError{err}
+		}
+		return matchPattern(joinFragments(frags, true), x) == (e.Op != "!="), nil
+	}
+	y, err := s.expandWord(e.Args[1])
+	if err != nil {
+		return false, expandError{err}
+	}
+	if intComparison(e.Op) {
+		a, err := s.evalArith(x)
+		if err != nil {
+			return false, err
+		}
+		b, err := s.evalArith(y)
+		if err != nil {
+			return false, err
+		}
+		return compareInts(e.Op, a, b), nil
+	}
+	return s.binaryTest(e.Op, x, y), nil
+}
+
+// matchRegex matches value against the extended regular expression in the
+// word re and records the match and its groups in BASH_REMATCH
+func (s *Shell) matchRegex(value, re string) (bool, error) {
+	frags, err := s.expandFragments(re, false, false)
+	if err != nil {
+		return false, expandError{err}
+	}
+	var b strings.Builder
+	for _, f := range frags {
+		if f.quoted {
+			b.WriteString(regexp.QuoteMeta(f.text))
+		} else {
+			b.WriteString(f.text)
+		}
+	}
+	compiled, err := regexp.CompilePOSIX(b.String())
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", b.String(), errBadRegex)
+	}
+
+	match := compiled.FindStringSubmatch(value)
+	groups := map[int]string{}
+	for i, m := range match {
+		groups[i] = m
+	}
+	if v, ok := s.vars["BASH_REMATCH"]; !ok || !v.ReadOnly {
+		s.vars["BASH_REMATCH"] = &Variable{Array: groups}
+	}
+	return match != nil, nil
+}
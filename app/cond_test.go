@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// condFixture creates a directory holding a non-empty executable file f, an
+// empty file e, a symlink l to f and a directory d
+func condFixture(t *testing.T) *Shell {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "f"), []byte("x\n"), 0o755)
+	os.WriteFile(filepath.Join(dir, "e"), nil, FilePermission)
+	os.Symlink("f", filepath.Join(dir, "l"))
+	os.Mkdir(filepath.Join(dir, "d"), 0o755)
+
+	shell := NewShell()
+	shell.cwd = dir
+	return shell
+}
+
+func TestShell_handleTest(t *testing.T) {
+	tests := map[string]struct {
+		name           string
+		args           []string
+		expectedStatus int
+	}{
+		"happy path - no arguments":              {name: "test", expectedStatus: 1},
+		"happy path - non-empty string":          {name: "test", args: []string{"x"}},
+		"happy path - empty string":              {name: "test", args: []string{""}, expectedStatus: 1},
+		"happy path - lone operator is a string": {name: "test", args: []string{"-f"}},
+		"happy path - file exists":               {name: "[", args: []string{"-e", "f", "]"}},
+		"happy path - missing file":              {name: "[", args: []string{"-e", "nope", "]"}, expectedStatus: 1},
+		"happy path - empty path":                {name: "test", args: []string{"-e", ""}, expectedStatus: 1},
+		"happy path - regular file":              {name: "test", args: []string{"-f", "d"}, expectedStatus: 1},
+		"happy path - directory":                 {name: "test", args: []string{"-d", "d"}},
+		"happy path - non-empty file":            {name: "test", args: []string{"-s", "e"}, expectedStatus: 1},
+		"happy path - symlink":                   {name: "test", args: []string{"-L", "l"}},
+		"happy path - executable":                {name: "test", args: []string{"-x", "f"}},
+		"happy path - character device":          {name: "test", args: []string{"-c", "/dev/null"}},
+		"happy path - string is empty":           {name: "test", args: []string{"-z", ""}},
+		"happy path - string is not empty":       {name: "test", args: []string{"-n", ""}, expectedStatus: 1},
+		"happy path - strings equal":             {name: "test", args: []string{"abc", "=", "abc"}},
+		"happy path - strings differ":            {name: "test", args: []string{"abc", "!=", "abc"}, expectedStatus: 1},
+		"happy path - string order":              {name: "test", args: []string{"a", "<", "b"}},
+		"happy path - equal is not a pattern":    {name: "test", args: []string{"abc", "=", "a*"}, expectedStatus: 1},
+		"happy path - integers":                  {name: "test", args: []string{" 10", "-gt", "9"}},
+		"happy path - integers not equal":        {name: "test", args: []string{"1", "-ne", "1"}, expectedStatus: 1},
+		"happy path - negation":                  {name: "test", args: []string{"!", "-e", "nope"}},
+		"happy path - and":                       {name: "test", args: []string{"-e", "f", "-a", "-d", "d"}},
+		"happy path - or":                        {name: "test", args: []string{"-e", "nope", "-o", "-d", "d"}},
+		"happy path - parentheses":               {name: "test", args: []string{"(", "-e", "f", ")"}},
+		"happy path - and binds tighter than or": {name: "test", args: []string{"x", "-o", "-e", "nope", "-a", "-e", "nope"}},
+		"happy path - nested expression":         {name: "test", args: []string{"!", "(", "-e", "f", "-a", "-e", "nope", ")", "-a", "1", "-gt", "0"}},
+		"happy path - newer file":                {name: "test", args: []string{"f", "-nt", "nope"}},
+		"happy path - same file":                 {name: "test", args: []string{"f", "-ef", "l"}},
+		"happy path - variable set":              {name: "test", args: []string{"-v", "GOSH_TEST_SET"}},
+		"sad path - missing bracket":             {name: "[", args: []string{"-e", "f"}, expectedStatus: 2},
+		"sad path - integer expected":            {name: "test", args: []string{"x", "-eq", "1"}, expectedStatus: 2},
+		"sad path - unary operator expected":     {name: "test", args: []string{"a", "b"}, expectedStatus: 2},
+		"sad path - binary operator expected":    {name: "test", args: []string{"a", "b", "c"}, expectedStatus: 2},
+		"sad path - too many arguments":          {name: "test", args: []string{"a", "=", "a", "b", "c"}, expectedStatus: 2},
+		"sad path - unclosed parenthesis":        {name: "test", args: []string{"(", "a", "-a", "b", "c"}, expectedStatus: 2},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := condFixture(t)
+			shell.setVar("GOSH_TEST_SET", "")
+			var stderr bytes.Buffer
+			status := shell.handleTest(tc.name, tc.args, testIO(io.Discard, &stderr))
+			if status != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d (%s)", tc.expectedStatus, status, stderr.String())
+			}
+			if (status == 2) != (stderr.Len() > 0) {
+				t.Errorf("expected an error message only for status 2, got %q", stderr.String())
+			}
+		})
+	}
+}
+
+func TestShell_runCond(t *testing.T) {
+	tests := map[string]struct {
+		input          string
+		expected       string
+		expectedStatus int
+	}{
+		"happy path - pattern": {
+			input: "[[ abc == a* ]]",
+		},
+		"happy path - quoted pattern is literal": {
+			input:          `[[ abc == "a*" ]]`,
+			expectedStatus: 1,
+		},
+		"happy path - pattern from a variable": {
+			input: "p='a?c'; [[ abc == $p ]]",
+		},
+		"happy path - pattern does not match": {
+			input: "[[ abc != b* ]]",
+		},
+		"happy path - no word splitting": {
+			input: `v="two words"; [[ $v == "two words" ]] && [[ -n $v ]]`,
+		},
+		"happy path - no pathname expansion": {
+			input: `x=*; [[ $x == "*" ]]`,
+		},
+		"happy path - unset operand is empty": {
+			input:          "[[ -n $GOSH_UNSET_VAR ]]",
+			expectedStatus: 1,
+		},
+		"happy path - regex groups": {
+			input:    "[[ foo123 =~ ^([a-z]+)([0-9]+)$ ]] && echo ${BASH_REMATCH[@]}",
+			expected: "foo123 foo 123\n",
+		},
+		"happy path - regex from a variable": {
+			input:    "re='^(x|y)+$'; [[ xyx =~ $re ]] && echo ${BASH_REMATCH[1]}",
+			expected: "x\n",
+		},
+		"happy path - regex match is leftmost longest": {
+			input:    "[[ ab =~ a|ab ]] && echo ${BASH_REMATCH[0]}",
+			expected: "ab\n",
+		},
+		"happy path - regex groups are leftmost longest": {
+			input:    "[[ xabcdx =~ (a|ab)(c|bcd) ]] && echo ${BASH_REMATCH[@]}",
+			expected: "abcd a bcd\n",
+		},
+		"happy path - quoted regex is literal": {
+			input:          `[[ abc =~ "a.c" ]]`,
+			expectedStatus: 1,
+		},
+		"happy path - failed match clears BASH_REMATCH": {
+			input:    "[[ ab =~ a ]]; [[ zz =~ q ]]; echo ${#BASH_REMATCH[@]}",
+			expected: "0\n",
+		},
+		"happy path - arithmetic comparison": {
+			input: "n=1; [[ n+1 -eq 2 ]]",
+		},
+		"happy path - string order": {
+			input:          "[[ 2 > 10 ]]",
+			expectedStatus: 0,
+		},
+		"happy path - and or with grouping": {
+			input: "[[ -e f && ( -d nope || -d d ) ]]",
+		},
+		"happy path - negation": {
+			input:          "[[ ! -e f || -e nope ]]",
+			expectedStatus: 1,
+		},
+		"happy path - lines inside": {
+			input: "[[ -e f &&\n   -d d ]]",
+		},
+		"happy path - in a condition": {
+			input:    "if [[ -d d ]]; then echo dir; fi",
+			expected: "dir\n",
+		},
+		"sad path - bad regex": {
+			input:          "[[ a =~ [ ]]",
+			expectedStatus: 2,
+		},
+		"sad path - bad arithmetic": {
+			input:          "x=')'; [[ 1 -eq $x ]]",
+			expectedStatus: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := condFixture(t)
+			cmd, err := shell.parseInput(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			var stdout bytes.Buffer
+			err = shell.runStages(cmd, newFdTable(strings.NewReader(""), &stdout, io.Discard))
+			if status := exitCode(err); status != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, status)
+			}
+			if stdout.String() != tc.expected {
+				t.Errorf("expected output %q, got %q", tc.expected, stdout.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,990 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// fragment is a piece of an expanded word. Quoted fragments come from quoted
+// or escaped text and match literally when the word is used as a pattern.
+// Split fragments are unquoted expansion results, subject to field
+// splitting. A fragment with sep set separates the elements of "$@" ('@')
+// or "$*" ('*'); it reads as a space where the word is not split.
+type fragment struct {
+	text   string
+	quoted bool
+	split  bool
+	sep    byte
+}
+
+// fragmentBuilder accumulates fragments, merging adjacent text of the same
+// kind. sawQuote records quotes that produced no text, so that "" still
+// yields an empty field, unless noFields records a "$@" without elements.
+type fragmentBuilder struct {
+	frags    []fragment
+	buf      strings.Builder
+	quoted   bool
+	split    bool
+	sawQuote bool
+	noFields bool
+}
+
+func (b *fragmentBuilder) write(text string, quoted bool) {
+	b.add(text, quoted, false)
+}
+
+func (b *fragmentBuilder) writeSplit(text string) {
+	b.add(text, false, true)
+}
+
+func (b *fragmentBuilder) add(text string, quoted, split bool) {
+	if (quoted != b.quoted || split != b.split) && b.buf.Len() > 0 {
+		b.flush()
+	}
+	b.quoted, b.split = quoted, split
+	b.buf.WriteString(text)
+}
+
+// breakField separates two elements of a list expansion
+func (b *fragmentBuilder) breakField(quoted bool) {
+	if b.buf.Len() > 0 {
+		b.flush()
+	}
+	b.frags = append(b.frags, fragment{text: " ", quoted: quoted, sep: '@'})
+}
+
+func (b *fragmentBuilder) flush() {
+	b.frags = append(b.frags, fragment{text: b.buf.String(), quoted: b.quoted, split: b.split})
+	b.buf.Reset()
+}
+
+func (b *fragmentBuilder) fragments() []fragment {
+	if b.buf.Len() > 0 {
+		b.flush()
+	}
+	if len(b.frags) == 0 && b.sawQuote && !b.noFields {
+		return []fragment{{quoted: true}}
+	}
+	return b.frags
+}
+
+// joinFragments concatenates fragments. For patterns, quoted fragments are
+// escaped so they only match themselves.
+func joinFragments(frags []fragment, pattern bool) string {
+	var b strings.Builder
+	for _, f := range frags {
+		if pattern && f.quoted {
+			b.WriteString(escapePattern(f.text))
+		} else {
+			b.WriteString(f.text)
+		}
+	}
+	return b.String()
+}
+
+// expandWords runs brace expansion, word expansion, field splitting and
+// then pathname expansion over the raw words of a command
+func (s *Shell) expandWords(words []string) ([]string, error) {
+	fields := make([]string, 0, len(words))
+	for _, word := range words {
+		for _, word := range expandBraces(word) {
+			frags, err := s.expandFragments(word, false, false)
+			if err != nil {
+				return fields, err
+			}
+			for _, field := range s.splitFields(frags) {
+				paths, err := s.expandPathname(field)
+				if err != nil {
+					return fields, err
+				}
+				fields = append(fields, paths...)
+			}
+		}
+	}
+	return fields, nil
+}
+
+// splitFields splits the split fragments of an expanded word at the
+// characters of IFS. Runs of IFS whitespace separate fields and are dropped
+// at either end; every other IFS character ends a field, even an empty one.
+// The elements of a list expansion always become separate fields, and in
+// double quotes even empty elements do.
+func (s *Shell) splitFields(frags []fragment) [][]fragment {
+	ifs, ok := s.getVar("IFS")
+	if !ok {
+		ifs = " \t\n"
+	}
+	isSpace := func(c byte) bool {
+		return strings.IndexByte(" \t\n", c) >= 0 && strings.IndexByte(ifs, c) >= 0
+	}
+
+	var fields [][]fragment
+	var field []fragment
+	inField := false
+	for _, f := range frags {
+		if f.sep != 0 {
+			if inField || f.quoted {
+				fields = append(fields, field)
+			}
+			field, inField = nil, f.quoted
+			continue
+		}
+		if !f.split {
+			field, inField = append(field, f), true
+			continue
+		}
+
+		text := f.text
+		for text != "" {
+			i := strings.IndexAny(text, ifs)
+			if i < 0 {
+				field, inField = append(field, fragment{text: text}), true
+				break
+			}
+			if i > 0 {
+				field, inField = append(field, fragment{text: text[:i]}), true
+			}
+
+			j := i
+			for j < len(text) && isSpace(text[j]) {
+				j++
+			}
+			delimited := false
+			if j < len(text) && strings.IndexByte(ifs, text[j]) >= 0 {
+				delimited = true
+				j++
+				for j < len(text) && isSpace(text[j]) {
+					j++
+				}
+			}
+			if inField || delimited {
+				fields = append(fields, field)
+				field, inField = nil, false
+			}
+			text = text[j:]
+		}
+	}
+	if inField {
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// expandWord expands a single raw word into one field, substituting
+// parameters outside single quotes and removing quotes
+func (s *Shell) expandWord(word string) (string, error) {
+	frags, err := s.expandFragments(word, false, false)
+	return joinFragments(frags, false), err
+}
+
+// expandPattern expands a word that is used as a pattern. Quoted parts of
+// the word are escaped so that they match literally.
+func (s *Shell) expandPattern(word string) (string, error) {
+	frags, err := s.expandFragments(word, false, false)
+	return s.extPattern(joinFragments(frags, true)), err
+}
+
+// expandAssignment expands the value of an assignment, where a tilde prefix
+// may also follow an unquoted ':' as in PATH=~/bin:~/go/bin
+func (s *Shell) expandAssignment(word string) (string, error) {
+	frags, err := s.expandFragments(word, false, true)
+	return joinFragments(frags, false), err
+}
+
+// expandHereDoc expands the body of a here-document whose delimiter was not
+// quoted. Parameters, command substitutions and arithmetic expand as in
+// double quotes, but quote characters are ordinary text.
+func (s *Shell) expandHereDoc(body string) (string, error) {
+	var b fragmentBuilder
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case c == Backslash && i+1 < len(body) && strings.IndexByte("\\$`\n", body[i+1]) >= 0:
+			if body[i+1] != '\n' {
+				b.write(string(body[i+1]), true)
+			}
+			i++
+		case c == '$':
+			value, n, err := s.expandDollar(body[i:], true)
+			if err != nil {
+				return "", err
+			}
+			s.writeExpansion(&b, value, true)
+			i += n - 1
+		case c == Backquote:
+			value, n, err := s.expandBackquote(body[i:], true)
+			if err != nil {
+				return "", err
+			}
+			b.write(value, true)
+			i += n - 1
+		default:
+			b.write(string(c), true)
+		}
+	}
+	return joinFragments(b.fragments(), false), nil
+}
+
+// expandFragments performs tilde and parameter expansion and quote removal
+// on word. inDouble is set when the word itself appears inside double
+// quotes, as the operand of "${name:-word}" does; assignment is set for the
+// value of an assignment.
+func (s *Shell) expandFragments(word string, inDouble, assignment bool) ([]fragment, error) {
+	var b fragmentBuilder
+	quoteChar := byte(0)
+	if inDouble {
+		quoteChar = DoubleQuote
+	}
+
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		switch {
+		case c == '~' && quoteChar == 0 && (i == 0 || assignment && word[i-1] == ':'):
+			if dir, n, ok := s.expandTilde(word[i:], assignment); ok {
+				b.write(dir, true)
+				i += n - 1
+			} else {
+				b.write(string(c), false)
+			}
+		case quoteChar == SingleQuote:
+			if c == SingleQuote {
+				quoteChar = 0
+			} else {
+				b.write(string(c), true)
+			}
+		case c == Backslash && i+1 < len(word):
+			next := word[i+1]
+			if quoteChar == DoubleQuote && !strings.ContainsRune("\\\"$`\n", rune(next)) {
+				b.write(string(c), true)
+				continue
+			}
+			if next != '\n' {
+				b.write(string(next), true)
+			}
+			i++
+		case c == '$':
+			value, n, err := s.expandDollar(word[i:], quoteChar == DoubleQuote)
+			if err != nil {
+				return nil, err
+			}
+			s.writeExpansion(&b, value, quoteChar == DoubleQuote)
+			i += n - 1
+		case c == Backquote:
+			value, n, err := s.expandBackquote(word[i:], quoteChar == DoubleQuote)
+			if err != nil {
+				return nil, err
+			}
+			s.writeExpansion(&b, scalar(value), quoteChar == DoubleQuote)
+			i += n - 1
+		case (c == '<' || c == '>') && quoteChar == 0 && strings.HasPrefix(word[i+1:], "("):
+			path, n, err := s.processSubstitution(word[i:])
+			if err != nil {
+				return nil, err
+			}
+			b.write(path, true)
+			i += n - 1
+		case quoteChar == DoubleQuote:
+			if c == DoubleQuote {
+				quoteChar = 0
+			} else {
+				b.write(string(c), true)
+			}
+		case c == SingleQuote || c == DoubleQuote:
+			quoteChar = c
+			b.sawQuote = true
+		default:
+			b.write(string(c), false)
+		}
+	}
+	return b.fragments(), nil
+}
+
+// expansion is the result of a parameter, arithmetic or command
+// substitution expansion. Unquoted fragments take the quoting of the place
+// the expansion appears in; noFields marks a list without elements.
+type expansion struct {
+	frags    []fragment
+	noFields bool
+}
+
+func scalar(value string) expansion {
+	return expansion{frags: []fragment{{text: value}}}
+}
+
+// listExpansion builds the expansion of "$@" or "${name[@]}" (kind '@'), or
+// of "$*" or "${name[*]}" (kind '*')
+func listExpansion(values []string, kind byte) expansion {
+	e := expansion{noFields: len(values) == 0}
+	for i, value := range values {
+		if i > 0 {
+			e.frags = append(e.frags, fragment{text: " ", sep: kind})
+		}
+		e.frags = append(e.frags, fragment{text: value})
+	}
+	return e
+}
+
+// text returns the expansion as a single string, list elements joined by
+// spaces
+func (e expansion) text() string {
+	return joinFragments(e.frags, false)
+}
+
+// each applies f to every value of the expansion
+func (e expansion) each(f func(string) (string, error)) (expansion, error) {
+	frags := make([]fragment, len(e.frags))
+	for i, frag := range e.frags {
+		if frag.sep == 0 {
+			text, err := f(frag.text)
+			if err != nil {
+				return expansion{}, err
+			}
+			frag.text = text
+		}
+		frags[i] = frag
+	}
+	return expansion{frags: frags, noFields: e.noFields}, nil
+}
+
+// writeExpansion adds an expansion to b. In double quotes the elements of
+// "$*" are joined by the first character of IFS; otherwise every list
+// element starts a new field and unquoted text is subject to splitting.
+func (s *Shell) writeExpansion(b *fragmentBuilder, e expansion, quoted bool) {
+	if quoted && e.noFields {
+		b.noFields = true
+	}
+	for _, f := range e.frags {
+		switch {
+		case f.sep == '*' && quoted:
+			b.write(s.ifsSeparator(), true)
+		case f.sep != 0:
+			b.breakField(quoted || f.quoted)
+		case quoted || f.quoted:
+			b.write(f.text, true)
+			b.sawQuote = true
+		default:
+			b.writeSplit(f.text)
+		}
+	}
+}
+
+// ifsSeparator returns the first character of IFS, which joins "$*"
+func (s *Shell) ifsSeparator() string {
+	ifs, ok := s.getVar("IFS")
+	if !ok {
+		return " "
+	}
+	if r, n := utf8.DecodeRuneInString(ifs); n > 0 {
+		return string(r)
+	}
+	return ""
+}
+
+// expandBackquote expands the `...` command substitution at the start of
+// word. Inside it a backslash only escapes '$', '`', '\' and, within
+// double quotes, '"'.
+func (s *Shell) expandBackquote(word string, inDouble bool) (string, int, error) {
+	l := lexer{src: word}
+	if err := l.skipBackquote(); err != nil {
+		return word[:1], 1, nil
+	}
+
+	var src strings.Builder
+	body := word[1 : l.pos-1]
+	for i := 0; i < len(body); i++ {
+		if body[i] == Backslash && i+1 < len(body) &&
+			(strings.IndexByte("$`\\", body[i+1]) >= 0 || inDouble && body[i+1] == DoubleQuote) {
+			i++
+		}
+		src.WriteByte(body[i])
+	}
+	value, err := s.commandSubstitution(src.String())
+	return value, l.pos, err
+}
+
+// commandSubstitution runs src in a subshell and returns its output without
+// trailing newlines. Its status becomes $?.
+func (s *Shell) commandSubstitution(src string) (string, error) {
+	list, err := parse(src)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	fds := s.inheritedFds()
+	fds[1] = &out
+	status := exitCode(s.subshell().runList(list, fds))
+	s.lastStatus, s.substStatus = status, status
+	return strings.TrimRight(out.String(), "\n"), nil
+}
+
+// expandTilde expands the tilde prefix at the start of word: "~" to HOME,
+// "~user" to that user's home directory, and "~+" and "~-" to PWD and
+// OLDPWD. It returns the directory and the length of the prefix, or false
+// if the prefix is quoted or names nothing.
+func (s *Shell) expandTilde(word string, assignment bool) (string, int, bool) {
+	end := strings.IndexByte(word, '/')
+	if assignment {
+		if colon := strings.IndexByte(word, ':'); colon >= 0 && (end < 0 || colon < end) {
+			end = colon
+		}
+	}
+	if end < 0 {
+		end = len(word)
+	}
+	prefix := word[1:end]
+	if strings.ContainsAny(prefix, "\\'\"$`") {
+		return "", 0, false
+	}
+
+	var dir string
+	var ok bool
+	switch prefix {
+	case "":
+		if dir, ok = s.getVar("HOME"); !ok {
+			if u, err := user.Current(); err == nil {
+				dir, ok = u.HomeDir, true
+			}
+		}
+	case "+":
+		dir, ok = s.getVar("PWD")
+	case "-":
+		dir, ok = s.getVar("OLDPWD")
+	default:
+		if u, err := user.Lookup(prefix); err == nil {
+			dir, ok = u.HomeDir, true
+		}
+	}
+	return dir, end, ok
+}
+
+// expandDollar expands the parameter reference at the start of word, which
+// begins with '$'. It returns the value and how many bytes were consumed; a
+// '$' that does not start a parameter reference is kept as it is, and unset
+// variables expand to nothing.
+func (s *Shell) expandDollar(word string, inDouble bool) (expansion, int, error) {
+	if len(word) < 2 {
+		return scalar("$"), 1, nil
+	}
+
+	if word[1] == '(' {
+		l := lexer{src: word}
+		if err := l.skipDollar(); err != nil {
+			return scalar("$"), 1, nil
+		}
+		if strings.HasPrefix(word, "$((") && strings.HasSuffix(word[:l.pos], "))") {
+			n, err := s.arithValue(word[3 : l.pos-2])
+			return scalar(strconv.Itoa(n)), l.pos, err
+		}
+		value, err := s.commandSubstitution(word[2 : l.pos-1])
+		return scalar(value), l.pos, err
+	}
+
+	if word[1] == '{' {
+		l := lexer{src: word}
+		if err := l.skipDollar(); err != nil {
+			return scalar("$"), 1, nil
+		}
+		value, err := s.expandBraced(word[2:l.pos-1], inDouble)
+		return value, l.pos, err
+	}
+
+	// Unbraced references take a single digit and no subscript
+	name := paramName(word[1:])
+	if i := strings.IndexByte(name, '['); i > 0 {
+		name = name[:i]
+	}
+	if name == "" {
+		return scalar("$"), 1, nil
+	}
+	if isDigit(name[0]) {
+		name = name[:1]
+	}
+	if values, list := s.paramValues(name); list != 0 {
+		return listExpansion(values, list), len(name) + 1, nil
+	}
+	value, _ := s.paramValue(name)
+	return scalar(value), len(name) + 1, nil
+}
+
+// expandBraced expands the contents of a "${...}" parameter expansion
+func (s *Shell) expandBraced(content string, inDouble bool) (expansion, error) {
+	if len(content) > 1 && content[0] == '#' {
+		name := content[1:]
+		if paramName(name) != name {
+			return expansion{}, fmt.Errorf("${%s}: bad substitution", content)
+		}
+		return scalar(strconv.Itoa(s.paramLength(name))), nil
+	}
+
+	name := paramName(content)
+	if name == "" {
+		return expansion{}, fmt.Errorf("${%s}: bad substitution", content)
+	}
+	value, set := s.paramValue(name)
+	result := scalar(value)
+	values, list := s.paramValues(name)
+	if list != 0 {
+		result = listExpansion(values, list)
+	}
+	op := content[len(name):]
+	if op == "" {
+		return result, nil
+	}
+
+	colon := false
+	if op[0] == ':' && len(op) > 1 && strings.IndexByte("-=?+", op[1]) >= 0 {
+		colon = true
+		op = op[1:]
+	}
+	// With a colon, a set but empty parameter counts as unset
+	present := set && (!colon || value != "")
+	operand := op[1:]
+
+	switch op[0] {
+	case '-':
+		if present {
+			return result, nil
+		}
+		return s.expandOperand(operand, inDouble)
+	case '=':
+		if present {
+			return result, nil
+		}
+		if !isName(name) {
+			return expansion{}, fmt.Errorf("$%s: cannot assign in this way", name)
+		}
+		value, err := s.expandOperand(operand, inDouble)
+		if err == nil {
+			s.setVar(name, value.text())
+		}
+		return value, err
+	case '?':
+		if present {
+			return result, nil
+		}
+		msg, err := s.expandOperand(operand, inDouble)
+		if err != nil {
+			return expansion{}, err
+		}
+		text := msg.text()
+		if text == "" && colon {
+			text = "parameter null or not set"
+		} else if text == "" {
+			text = "parameter not set"
+		}
+		return expansion{}, fmt.Errorf("%s: %s", name, text)
+	case '+':
+		if present {
+			return s.expandOperand(operand, inDouble)
+		}
+		return scalar(""), nil
+	case '#', '%':
+		longest := len(operand) > 0 && operand[0] == op[0]
+		if longest {
+			operand = operand[1:]
+		}
+		pattern, err := s.expandPatternOperand(operand, inDouble)
+		if err != nil {
+			return expansion{}, err
+		}
+		return result.each(func(value string) (string, error) {
+			if op[0] == '#' {
+				return trimPrefixPattern(value, pattern, longest), nil
+			}
+			return trimSuffixPattern(value, pattern, longest), nil
+		})
+	case '/':
+		return result.each(func(value string) (string, error) {
+			return s.replacePattern(value, operand, inDouble)
+		})
+	case ':':
+		if list == 0 {
+			value, err := s.substring(value, operand)
+			return scalar(value), err
+		}
+		if name == "@" || name == "*" {
+			// Offsets into the positional parameters count $0 as 0
+			values = append([]string{s.arg0}, s.params...)
+		}
+		start, end, err := s.sliceBounds(len(values), operand)
+		if err != nil {
+			return expansion{}, err
+		}
+		return listExpansion(values[start:end], list), nil
+	}
+	return expansion{}, fmt.Errorf("${%s}: bad substitution", content)
+}
+
+// expandOperand expands the word operand of a "${name<op>word}" expansion
+func (s *Shell) expandOperand(operand string, inDouble bool) (expansion, error) {
+	frags, err := s.expandFragments(operand, inDouble, false)
+	return expansion{frags: frags}, err
+}
+
+// expandPatternOperand expands the pattern operand of a "${name<op>pattern}"
+// expansion
+func (s *Shell) expandPatternOperand(operand string, inDouble bool) (string, error) {
+	frags, err := s.expandFragments(operand, inDouble, false)
+	return s.extPattern(joinFragments(frags, true)), err
+}
+
+// replacePattern implements "${name/pattern/string}" and its variants:
+// "//" replaces every match, "/#" anchors the match at the start and "/%" at
+// the end. Matches are as long as possible.
+func (s *Shell) replacePattern(value, operand string, inDouble bool) (string, error) {
+	mode := byte(0)
+	if operand != "" && strings.IndexByte("/#%", operand[0]) >= 0 {
+		mode = operand[0]
+		operand = operand[1:]
+	}
+
+	rawPattern, rawReplacement, _ := cutUnquoted(operand, '/')
+	pattern, err := s.expandPatternOperand(rawPattern, inDouble)
+	if err != nil {
+		return "", err
+	}
+	operandValue, err := s.expandOperand(rawReplacement, inDouble)
+	if err != nil || pattern == "" {
+		return value, err
+	}
+	replacement := operandValue.text()
+
+	bounds := runeBounds(value)
+	var b strings.Builder
+	last := 0
+	for _, start := range bounds {
+		if start < last {
+			continue
+		}
+		if mode == '#' && start > 0 {
+			break
+		}
+		end := longestMatch(pattern, value, start, bounds, mode == '%')
+		if end < 0 {
+			continue
+		}
+		b.WriteString(value[last:start])
+		b.WriteString(replacement)
+		last = end
+		if mode != '/' {
+			break
+		}
+	}
+	b.WriteString(value[last:])
+	return b.String(), nil
+}
+
+// longestMatch returns the end of the longest match of pattern that starts
+// at value[start:], or -1 if there is none. With anchorEnd the match must
+// extend to the end of value.
+func longestMatch(pattern, value string, start int, bounds []int, anchorEnd bool) int {
+	for j := len(bounds) - 1; j >= 0 && bounds[j] >= start; j-- {
+		if anchorEnd && bounds[j] != len(value) {
+			continue
+		}
+		if matchPattern(pattern, value[start:bounds[j]]) {
+			return bounds[j]
+		}
+	}
+	return -1
+}
+
+// substring implements "${name:offset}" and "${name:offset:length}".
+// Negative offsets count from the end of the value; a negative length marks
+// the end of the substring counted from the end of the value.
+func (s *Shell) substring(value, operand string) (string, error) {
+	runes := []rune(value)
+	start, end, err := s.sliceBounds(len(runes), operand)
+	return string(runes[start:end]), err
+}
+
+// sliceBounds evaluates the "offset:length" operand of a substring or list
+// slice over n items. Negative offsets count from the end, as do negative
+// lengths, which give the end position instead.
+func (s *Shell) sliceBounds(n int, operand string) (int, int, error) {
+	rawOffset, rawLength, hasLength := strings.Cut(operand, ":")
+
+	offset, err := s.evalOffset(rawOffset)
+	if err != nil {
+		return 0, 0, err
+	}
+	if offset < 0 {
+		offset += n
+	}
+	if offset < 0 || offset > n {
+		return 0, 0, nil
+	}
+
+	end := n
+	if hasLength {
+		length, err := s.evalOffset(rawLength)
+		if err != nil {
+			return 0, 0, err
+		}
+		if length < 0 {
+			end += length
+			if end < offset {
+				return 0, 0, fmt.Errorf("%s: substring expression < 0", strings.TrimSpace(rawLength))
+			}
+		} else {
+			end = min(offset+length, end)
+		}
+	}
+	return offset, end, nil
+}
+
+// evalOffset evaluates an offset or length in a substring expansion, which
+// is an arithmetic expression
+func (s *Shell) evalOffset(expr string) (int, error) {
+	return s.arithValue(expr)
+}
+
+// trimPrefixPattern removes the shortest (or longest) prefix of value that
+// matches pattern
+func trimPrefixPattern(value, pattern string, longest bool) string {
+	bounds := runeBounds(value)
+	for k := range bounds {
+		i := bounds[k]
+		if longest {
+			i = bounds[len(bounds)-1-k]
+		}
+		if matchPattern(pattern, value[:i]) {
+			return value[i:]
+		}
+	}
+	return value
+}
+
+// trimSuffixPattern removes the shortest (or longest) suffix of value that
+// matches pattern
+func trimSuffixPattern(value, pattern string, longest bool) string {
+	bounds := runeBounds(value)
+	for k := range bounds {
+		i := bounds[len(bounds)-1-k]
+		if longest {
+			i = bounds[k]
+		}
+		if matchPattern(pattern, value[i:]) {
+			return value[:i]
+		}
+	}
+	return value
+}
+
+// runeBounds returns the byte offsets of every rune boundary in s,
+// including 0 and len(s)
+func runeBounds(s string) []int {
+	bounds := make([]int, 0, len(s)+1)
+	for i := range s {
+		bounds = append(bounds, i)
+	}
+	return append(bounds, len(s))
+}
+
+// cutUnquoted splits s around the first sep that is not quoted or escaped
+func cutUnquoted(s string, sep byte) (before, after string, found bool) {
+	quoteChar := byte(0)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == Backslash && quoteChar != SingleQuote:
+			i++
+		case quoteChar != 0:
+			if c == quoteChar {
+				quoteChar = 0
+			}
+		case c == SingleQuote || c == DoubleQuote:
+			quoteChar = c
+		case c == sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+// paramName returns the parameter reference at the start of s: a special
+// parameter, a run of digits, or a variable name with an optional subscript
+func paramName(s string) string {
+	if s == "" {
+		return ""
+	}
+	if strings.IndexByte("?$#@*!-", s[0]) >= 0 {
+		return s[:1]
+	}
+
+	n := 0
+	if isDigit(s[0]) {
+		for n < len(s) && isDigit(s[n]) {
+			n++
+		}
+		return s[:n]
+	}
+	for n < len(s) && isNameChar(s[n]) {
+		n++
+	}
+	if n > 0 && n < len(s) && s[n] == '[' {
+		if end := strings.IndexByte(s[n:], ']'); end > 1 {
+			n += end + 1
+		}
+	}
+	return s[:n]
+}
+
+// paramValue returns the value of a special parameter or shell variable and
+// whether it is set. Arrays may be subscripted as in name[1]; the subscripts
+// "@" and "*" select every element.
+func (s *Shell) paramValue(param string) (string, bool) {
+	name, index := splitSubscript(param)
+
+	switch name {
+	case "?":
+		return strconv.Itoa(s.lastStatus), true
+	case "$":
+		return strconv.Itoa(os.Getpid()), true
+	case "0":
+		return s.arg0, true
+	case "#":
+		return strconv.Itoa(len(s.params)), true
+	case "@", "*":
+		return strings.Join(s.params, " "), len(s.params) > 0
+	case "PIPESTATUS":
+		values := make([]string, len(s.pipeStatus))
+		for i, status := range s.pipeStatus {
+			values[i] = strconv.Itoa(status)
+		}
+		return arrayElement(values, index), true
+	}
+
+	if isDigit(name[0]) {
+		n, err := strconv.Atoi(name)
+		switch {
+		case err != nil || n > len(s.params):
+			return "", false
+		case n == 0:
+			return s.arg0, true
+		}
+		return s.params[n-1], true
+	}
+
+	switch index {
+	case "":
+		return s.getVar(name)
+	case "@", "*":
+		v, ok := s.vars[name]
+		if !ok {
+			return "", false
+		}
+		return strings.Join(v.values(), " "), true
+	}
+	return s.element(name, index)
+}
+
+// paramValues returns the values of a list parameter, "$@", "$*",
+// "${name[@]}" or "${name[*]}", along with '@' or '*'. For any other
+// parameter the kind is 0.
+func (s *Shell) paramValues(param string) ([]string, byte) {
+	if param == "@" || param == "*" {
+		return s.params, param[0]
+	}
+	name, index := splitSubscript(param)
+	if index != "@" && index != "*" {
+		return nil, 0
+	}
+	if name == "PIPESTATUS" {
+		values := make([]string, len(s.pipeStatus))
+		for i, status := range s.pipeStatus {
+			values[i] = strconv.Itoa(status)
+		}
+		return values, index[0]
+	}
+	if v, ok := s.vars[name]; ok {
+		return v.values(), index[0]
+	}
+	return nil, index[0]
+}
+
+// paramLength implements "${#name}": the length of a value, or the number
+// of elements for name[@] and name[*]
+func (s *Shell) paramLength(param string) int {
+	if param == "@" || param == "*" {
+		return len(s.params)
+	}
+	name, index := splitSubscript(param)
+	if index == "@" || index == "*" {
+		if name == "PIPESTATUS" {
+			return len(s.pipeStatus)
+		}
+		if v, ok := s.vars[name]; ok {
+			return len(v.keys())
+		}
+		return 0
+	}
+	value, _ := s.paramValue(param)
+	return utf8.RuneCountInString(value)
+}
+
+// arrayElement returns the element selected by a subscript: "@" and "*"
+// select all elements joined by spaces, and no subscript selects element 0
+func arrayElement(values []string, index string) string {
+	switch index {
+	case "@", "*":
+		return strings.Join(values, " ")
+	case "":
+		index = "0"
+	}
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i >= len(values) {
+		return ""
+	}
+	return values[i]
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// removeQuotes strips quoting from a raw word: backslash escapes outside
+// quotes, single-quoted text verbatim, and the escapes double quotes allow
+func removeQuotes(word string) string {
+	var b strings.Builder
+	quoteChar := byte(0)
+
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		switch {
+		case quoteChar == SingleQuote:
+			if c == SingleQuote {
+				quoteChar = 0
+			} else {
+				b.WriteByte(c)
+			}
+		case c == Backslash && i+1 < len(word):
+			next := word[i+1]
+			if quoteChar == DoubleQuote && !strings.ContainsRune("\\\"$`\n", rune(next)) {
+				b.WriteByte(c)
+				continue
+			}
+			if next != '\n' {
+				b.WriteByte(next)
+			}
+			i++
+		case quoteChar == DoubleQuote:
+			if c == DoubleQuote {
+				quoteChar = 0
+			} else {
+				b.WriteByte(c)
+			}
+		case c == SingleQuote || c == DoubleQuote:
+			quoteChar = c
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
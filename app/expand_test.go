@@ -0,0 +1,462 @@
+package main
+
+import (
+	"os/user"
+	"testing"
+)
+
+func TestShell_expandWord_Status(t *testing.T) {
+	shell := NewShell()
+	shell.lastStatus = 3
+	shell.pipeStatus = []int{0, 1, 3}
+
+	tests := map[string]struct {
+		word     string
+		expected string
+	}{
+		"happy path - last status":                   {word: "$?", expected: "3"},
+		"happy path - braced last status":            {word: "${?}", expected: "3"},
+		"happy path - status in double quotes":       {word: `"status=$?"`, expected: "status=3"},
+		"happy path - no expansion in single quotes": {word: `'$?'`, expected: "$?"},
+		"happy path - escaped dollar":                {word: `\$?`, expected: "$?"},
+		"happy path - pipestatus element":            {word: "${PIPESTATUS[1]}", expected: "1"},
+		"happy path - pipestatus all":                {word: "${PIPESTATUS[@]}", expected: "0 1 3"},
+		"happy path - pipestatus first":              {word: "$PIPESTATUS", expected: "0"},
+		"edge case - lone dollar":                    {word: "$", expected: "$"},
+		"edge case - out of range element":           {word: "${PIPESTATUS[7]}", expected: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := shell.expandWord(tc.word)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestShell_expandWord_Variables(t *testing.T) {
+	shell := NewShell()
+	shell.setVar("NAME", "world")
+	shell.setVar("EMPTY", "")
+
+	tests := map[string]struct {
+		word     string
+		expected string
+	}{
+		"happy path - plain variable":                  {word: "$NAME", expected: "world"},
+		"happy path - braced variable":                 {word: "${NAME}s", expected: "worlds"},
+		"happy path - variable in double quotes":       {word: `"hello $NAME"`, expected: "hello world"},
+		"happy path - no expansion in single quotes":   {word: `'$NAME'`, expected: "$NAME"},
+		"happy path - escaped dollar":                  {word: `\$NAME`, expected: "$NAME"},
+		"happy path - escaped dollar in double quotes": {word: `"\$NAME"`, expected: "$NAME"},
+		"happy path - name stops at non-name char":     {word: "$NAME.txt", expected: "world.txt"},
+		"happy path - adjacent variables":              {word: "$NAME$NAME", expected: "worldworld"},
+		"edge case - unset variable":                   {word: "a${UNSET_XYZ}b", expected: "ab"},
+		"edge case - empty variable":                   {word: "$EMPTY", expected: ""},
+		"edge case - dollar before space":              {word: `"$ x"`, expected: "$ x"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := shell.expandWord(tc.word)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestShell_expandWord_Tilde(t *testing.T) {
+	shell := NewShell()
+	shell.setVar("HOME", "/home/me")
+	shell.setVar("PWD", "/work")
+	shell.setVar("OLDPWD", "/prev")
+	current, err := user.Current()
+	if err != nil {
+		t.Skip("no current user")
+	}
+
+	tests := map[string]struct {
+		word     string
+		expected string
+	}{
+		"happy path - bare tilde":         {word: "~", expected: "/home/me"},
+		"happy path - tilde with path":    {word: "~/src", expected: "/home/me/src"},
+		"happy path - current directory":  {word: "~+/x", expected: "/work/x"},
+		"happy path - previous directory": {word: "~-", expected: "/prev"},
+		"happy path - user home":          {word: "~" + current.Username, expected: current.HomeDir},
+		"happy path - operand of default": {word: "${UNSET_XYZ:-~/x}", expected: "/home/me/x"},
+		"edge case - quoted tilde":        {word: `"~"`, expected: "~"},
+		"edge case - escaped tilde":       {word: `\~`, expected: "~"},
+		"edge case - quoted prefix":       {word: `~"root"`, expected: "~root"},
+		"edge case - tilde inside word":   {word: "a~", expected: "a~"},
+		"edge case - unknown user":        {word: "~nonexistent_user_xyz/x", expected: "~nonexistent_user_xyz/x"},
+		"edge case - not after colon":     {word: "a:~", expected: "a:~"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := shell.expandWord(tc.word)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestShell_expandAssignment_Tilde(t *testing.T) {
+	shell := NewShell()
+	shell.setVar("HOME", "/home/me")
+
+	tests := map[string]struct {
+		word     string
+		expected string
+	}{
+		"happy path - value start":    {word: "~/bin", expected: "/home/me/bin"},
+		"happy path - after colons":   {word: "~/bin:/usr/bin:~/go", expected: "/home/me/bin:/usr/bin:/home/me/go"},
+		"happy path - tilde to colon": {word: "~:x", expected: "/home/me:x"},
+		"edge case - quoted":          {word: `/a:"~"`, expected: "/a:~"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := shell.expandAssignment(tc.word)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestShell_expandWords_CommandSubstitution(t *testing.T) {
+	shell := NewShell()
+	shell.setVar("NAME", "world")
+
+	tests := map[string]struct {
+		word     string
+		expected []string
+	}{
+		"happy path - dollar paren":          {word: "$(echo hi)", expected: []string{"hi"}},
+		"happy path - backquotes":            {word: "`echo hi`", expected: []string{"hi"}},
+		"happy path - unquoted is split":     {word: "$(echo a b)", expected: []string{"a", "b"}},
+		"happy path - quoted is not split":   {word: `"$(echo 'a  b')"`, expected: []string{"a  b"}},
+		"happy path - trailing newlines":     {word: `"$(printf 'a\n\n')"`, expected: []string{"a"}},
+		"happy path - nested":                {word: `"$(echo "$(echo in)")"`, expected: []string{"in"}},
+		"happy path - nested backquotes":     {word: "`echo \\`echo in\\``", expected: []string{"in"}},
+		"happy path - sees shell variables":  {word: "$(echo $NAME)", expected: []string{"world"}},
+		"happy path - joins adjacent text":   {word: "a$(echo ' b c ')d", expected: []string{"a", "b", "c", "d"}},
+		"happy path - parenthesis in quotes": {word: `$(echo ")")`, expected: []string{")"}},
+		"happy path - subshell inside":       {word: "$( (echo sub) )", expected: []string{"sub"}},
+		"edge case - empty output":           {word: "$(true)", expected: []string{}},
+		"edge case - empty quoted output":    {word: `"$(true)"`, expected: []string{""}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := shell.expandWords([]string{tc.word})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(result) != len(tc.expected) {
+				t.Fatalf("expected %q, got %q", tc.expected, result)
+			}
+			for i := range result {
+				if result[i] != tc.expected[i] {
+					t.Errorf("expected %q, got %q", tc.expected, result)
+				}
+			}
+		})
+	}
+}
+
+func TestShell_expandWords_Fields(t *testing.T) {
+	shell := NewShell()
+	shell.arg0 = "gosh"
+	shell.params = []string{"a b", "", "c"}
+	shell.setVar("X", "1  2   3")
+	shell.setVar("CSV", "a,b,,c")
+	shell.assign(`ARR=("p q" r)`)
+
+	tests := map[string]struct {
+		word     string
+		ifs      string
+		expected []string
+	}{
+		"happy path - quoted at keeps every parameter":   {word: `"$@"`, expected: []string{"a b", "", "c"}},
+		"happy path - unquoted at is split":              {word: "$@", expected: []string{"a", "b", "c"}},
+		"happy path - quoted star is joined":             {word: `"$*"`, expected: []string{"a b  c"}},
+		"happy path - quoted star joins with IFS":        {word: `"$*"`, ifs: ":", expected: []string{"a b::c"}},
+		"happy path - at with prefix and suffix":         {word: `"x$@y"`, expected: []string{"xa b", "", "cy"}},
+		"happy path - at slice":                          {word: `"${@:2}"`, expected: []string{"", "c"}},
+		"happy path - operator on each parameter":        {word: `"${@/b/B}"`, expected: []string{"a B", "", "c"}},
+		"happy path - unquoted variable is split":        {word: "$X", expected: []string{"1", "2", "3"}},
+		"happy path - quoted variable is not split":      {word: `"$X"`, expected: []string{"1  2   3"}},
+		"happy path - non-whitespace separators":         {word: "$CSV", ifs: ",", expected: []string{"a", "b", "", "c"}},
+		"happy path - quoted array elements":             {word: `"${ARR[@]}"`, expected: []string{"p q", "r"}},
+		"happy path - quoted default is one field":       {word: `${U:-"a b"}`, expected: []string{"a b"}},
+		"happy path - unquoted default is split":         {word: "${U:-a b}", expected: []string{"a", "b"}},
+		"edge case - unset variable leaves no field":     {word: "$U", expected: []string{}},
+		"edge case - zero with leading zeros":            {word: "${00}", expected: []string{"gosh"}},
+		"edge case - length of zero with leading zeros":  {word: "${#00}", expected: []string{"4"}},
+		"edge case - parameter past int range is unset":  {word: "${99999999999999999999}", expected: []string{}},
+		"edge case - length of parameter past int range": {word: "${#99999999999999999999}", expected: []string{"0"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.ifs == "" {
+				tc.ifs = " \t\n"
+			}
+			shell.setVar("IFS", tc.ifs)
+
+			result, err := shell.expandWords([]string{tc.word})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(result) != len(tc.expected) {
+				t.Fatalf("expected %q, got %q", tc.expected, result)
+			}
+			for i := range result {
+				if result[i] != tc.expected[i] {
+					t.Errorf("expected %q, got %q", tc.expected, result)
+				}
+			}
+		})
+	}
+}
+
+func TestShell_expandHereDoc(t *testing.T) {
+	shell := NewShell()
+	shell.setVar("X", "a  b")
+
+	tests := map[string]struct {
+		body     string
+		expected string
+	}{
+		"happy path - parameters are not split":   {body: "$X\n", expected: "a  b\n"},
+		"happy path - quotes are literal":         {body: `"$X" '$X'`, expected: `"a  b" 'a  b'`},
+		"happy path - escaped dollar":             {body: `\$X \\`, expected: `$X \`},
+		"happy path - other backslashes are kept": {body: `\n \"`, expected: `\n \"`},
+		"happy path - escaped newline joins":      {body: "a\\\nb", expected: "ab"},
+		"happy path - substitutions":              {body: "$(echo c) `echo d` $((1+1))", expected: "c d 2"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := shell.expandHereDoc(tc.body)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestShell_splitFields(t *testing.T) {
+	tests := map[string]struct {
+		ifs      string
+		frags    []fragment
+		expected []string
+	}{
+		"happy path - whitespace": {
+			ifs:      " \t\n",
+			frags:    []fragment{{text: "  a \t b\n", split: true}},
+			expected: []string{"a", "b"},
+		},
+		"happy path - custom separator": {
+			ifs:      ":",
+			frags:    []fragment{{text: "a::b:", split: true}},
+			expected: []string{"a", "", "b"},
+		},
+		"happy path - separator with whitespace": {
+			ifs:      ": ",
+			frags:    []fragment{{text: "a : b", split: true}},
+			expected: []string{"a", "b"},
+		},
+		"happy path - leading separator": {
+			ifs:      ":",
+			frags:    []fragment{{text: ":a", split: true}},
+			expected: []string{"", "a"},
+		},
+		"happy path - quoted text is kept whole": {
+			ifs:      " \t\n",
+			frags:    []fragment{{text: "x y", quoted: true}, {text: " z", split: true}},
+			expected: []string{"x y", "z"},
+		},
+		"edge case - empty IFS": {
+			ifs:      "",
+			frags:    []fragment{{text: "a b", split: true}},
+			expected: []string{"a b"},
+		},
+		"edge case - only whitespace": {
+			ifs:      " \t\n",
+			frags:    []fragment{{text: "   ", split: true}},
+			expected: []string{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			shell.setVar("IFS", tc.ifs)
+
+			fields := shell.splitFields(tc.frags)
+			if len(fields) != len(tc.expected) {
+				t.Fatalf("expected %q, got %v", tc.expected, fields)
+			}
+			for i, field := range fields {
+				if result := joinFragments(field, false); result != tc.expected[i] {
+					t.Errorf("field %d: expected %q, got %q", i, tc.expected[i], result)
+				}
+			}
+		})
+	}
+}
+
+func TestShell_expandWord_Arithmetic(t *testing.T) {
+	shell := NewShell()
+	shell.setVar("X", "4")
+
+	tests := map[string]struct {
+		word     string
+		expected string
+	}{
+		"happy path - expression":           {word: "$((1 + 2 * 3))", expected: "7"},
+		"happy path - variable without $":   {word: "$((X * 2))", expected: "8"},
+		"happy path - parameter expansion":  {word: "$(($X + ${X}))", expected: "8"},
+		"happy path - command substitution": {word: "$(( $(echo 3) ** 2 ))", expected: "9"},
+		"happy path - in double quotes":     {word: `"n=$((X - 5))"`, expected: "n=-1"},
+		"happy path - nested parentheses":   {word: "$(( (1 + 1) * (2 + 2) ))", expected: "8"},
+		"happy path - substring offset":     {word: "${X:0:1+0}", expected: "4"},
+		"edge case - command substitution":  {word: "$( (echo sub) )", expected: "sub"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := shell.expandWord(tc.word)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestShell_expandWord_Operators(t *testing.T) {
+	tests := map[string]struct {
+		word     string
+		expected string
+	}{
+		"happy path - default for unset":                  {word: "${UNSET_XYZ:-fallback}", expected: "fallback"},
+		"happy path - default for empty with colon":       {word: "${EMPTY:-fallback}", expected: "fallback"},
+		"happy path - no default for empty without colon": {word: "${EMPTY-fallback}", expected: ""},
+		"happy path - default not used when set":          {word: "${FILE:-fallback}", expected: "archive.tar.gz"},
+		"happy path - default is expanded":                {word: "${UNSET_XYZ:-$FILE}", expected: "archive.tar.gz"},
+		"happy path - default with spaces":                {word: `"${UNSET_XYZ:-a  b}"`, expected: "a  b"},
+		"happy path - alternate when set":                 {word: "${FILE:+alt}", expected: "alt"},
+		"happy path - alternate when unset":               {word: "${UNSET_XYZ:+alt}", expected: ""},
+		"happy path - alternate for empty without colon":  {word: "${EMPTY+alt}", expected: "alt"},
+		"happy path - length":                             {word: "${#FILE}", expected: "14"},
+		"happy path - length of multibyte value":          {word: "${#UNICODE}", expected: "4"},
+		"happy path - shortest prefix":                    {word: "${FILE#*.}", expected: "tar.gz"},
+		"happy path - longest prefix":                     {word: "${FILE##*.}", expected: "gz"},
+		"happy path - shortest suffix":                    {word: "${FILE%.*}", expected: "archive.tar"},
+		"happy path - longest suffix":                     {word: "${FILE%%.*}", expected: "archive"},
+		"happy path - quoted pattern is literal":          {word: `${STARS#"*"}`, expected: "*b*"},
+		"happy path - bracket pattern":                    {word: "${FILE#[a-c]}", expected: "rchive.tar.gz"},
+		"happy path - pattern without match":              {word: "${FILE#zzz}", expected: "archive.tar.gz"},
+		"happy path - replace first":                      {word: "${PATHLIKE/:/;}", expected: "a;b:c"},
+		"happy path - replace all":                        {word: "${PATHLIKE//:/;}", expected: "a;b;c"},
+		"happy path - replace anchored start":             {word: "${PATHLIKE/#a/x}", expected: "x:b:c"},
+		"happy path - replace anchored end":               {word: "${PATHLIKE/%c/x}", expected: "a:b:x"},
+		"happy path - replace with pattern":               {word: "${FILE/.*/}", expected: "archive"},
+		"happy path - delete all matches":                 {word: "${PATHLIKE//:}", expected: "abc"},
+		"happy path - substring offset":                   {word: "${FILE:8}", expected: "tar.gz"},
+		"happy path - substring offset and length":        {word: "${FILE:0:7}", expected: "archive"},
+		"happy path - negative offset":                    {word: "${FILE: -2}", expected: "gz"},
+		"happy path - negative offset in parens":          {word: "${FILE:(-6):3}", expected: "tar"},
+		"happy path - negative length":                    {word: "${FILE:8:-3}", expected: "tar"},
+		"edge case - offset past end":                     {word: "${FILE:50}", expected: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			shell.setVar("FILE", "archive.tar.gz")
+			shell.setVar("EMPTY", "")
+			shell.setVar("UNICODE", "日本語!")
+			shell.setVar("STARS", "**b*")
+			shell.setVar("PATHLIKE", "a:b:c")
+
+			result, err := shell.expandWord(tc.word)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestShell_expandWord_AssignDefault(t *testing.T) {
+	shell := NewShell()
+
+	result, err := shell.expandWord("${NEW_XYZ:=created}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "created" {
+		t.Errorf("expected %q, got %q", "created", result)
+	}
+	if value, _ := shell.getVar("NEW_XYZ"); value != "created" {
+		t.Errorf("expected NEW_XYZ to be assigned, got %q", value)
+	}
+}
+
+func TestShell_expandWord_Errors(t *testing.T) {
+	shell := NewShell()
+	shell.setVar("EMPTY", "")
+
+	tests := map[string]struct {
+		word        string
+		expectedErr string
+	}{
+		"sad path - unset with message":         {word: "${UNSET_XYZ:?must be set}", expectedErr: "UNSET_XYZ: must be set"},
+		"sad path - empty with default message": {word: "${EMPTY:?}", expectedErr: "EMPTY: parameter null or not set"},
+		"sad path - unset without colon":        {word: "${UNSET_XYZ?}", expectedErr: "UNSET_XYZ: parameter not set"},
+		"sad path - message is expanded":        {word: "${UNSET_XYZ:?status $?}", expectedErr: "UNSET_XYZ: status 0"},
+		"sad path - bad substitution":           {word: "${FILE^^}", expectedErr: "${FILE^^}: bad substitution"},
+		"sad path - assign to special":          {word: "${1:=x}", expectedErr: "$1: cannot assign in this way"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := shell.expandWord(tc.word)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if err.Error() != tc.expectedErr {
+				t.Errorf("expected error %q, got %q", tc.expectedErr, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// matchPattern reports whether s matches the shell pattern as a whole.
+// Patterns support '*', '?', bracket expressions such as [a-z], [!0-9] and
+// [[:alpha:]], the extended patterns ?(...), *(...), +(...), @(...) and
+// !(...) over '|'-separated alternatives, and backslash escapes that make
+// the next character literal.
+func matchPattern(pattern, s string) bool {
+	for len(pattern) > 0 {
+		if alts, rest, ok := cutExtglob(pattern); ok {
+			return matchExtglob(pattern[0], alts, rest, s)
+		}
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if i < len(s) && !utf8.RuneStart(s[i]) {
+					continue
+				}
+				if matchPattern(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+			_, n := utf8.DecodeRuneInString(s)
+			pattern, s = pattern[1:], s[n:]
+		case '[':
+			if s == "" {
+				return false
+			}
+			r, n := utf8.DecodeRuneInString(s)
+			matched, width, ok := matchBracket(pattern, r)
+			if !ok {
+				// An unterminated bracket is an ordinary character
+				if s[0] != '[' {
+					return false
+				}
+				pattern, s = pattern[1:], s[1:]
+				continue
+			}
+			if !matched {
+				return false
+			}
+			pattern, s = pattern[width:], s[n:]
+		default:
+			if pattern[0] == Backslash && len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			pr, pn := utf8.DecodeRuneInString(pattern)
+			r, n := utf8.DecodeRuneInString(s)
+			if s == "" || pr != r {
+				return false
+			}
+			pattern, s = pattern[pn:], s[n:]
+		}
+	}
+	return s == ""
+}
+
+// cutExtglob splits the extended pattern at the start of pattern into its
+// alternatives and the rest of the pattern. ok is false if pattern does not
+// start with one, or if its parenthesis is not closed.
+func cutExtglob(pattern string) (alts []string, rest string, ok bool) {
+	if len(pattern) < 2 || pattern[1] != '(' || strings.IndexByte("?*+@!", pattern[0]) < 0 {
+		return nil, "", false
+	}
+	depth, start := 0, 2
+	for i := 2; i < len(pattern); i++ {
+		switch pattern[i] {
+		case Backslash:
+			i++
+		case '(':
+			depth++
+		case '|':
+			if depth == 0 {
+				alts = append(alts, pattern[start:i])
+				start = i + 1
+			}
+		case ')':
+			if depth == 0 {
+				return append(alts, pattern[start:i]), pattern[i+1:], true
+			}
+			depth--
+		}
+	}
+	return nil, "", false
+}
+
+// matchExtglob reports whether s matches an extended pattern, given its
+// operator and alternatives, followed by rest
+func matchExtglob(op byte, alts []string, rest, s string) bool {
+	matchesAlt := func(s string) bool {
+		for _, alt := range alts {
+			if matchPattern(alt, s) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) && !utf8.RuneStart(s[i]) {
+			continue
+		}
+		head, tail := s[:i], s[i:]
+		switch op {
+		case '?', '@':
+			if matchesAlt(head) && matchPattern(rest, tail) {
+				return true
+			}
+		case '!':
+			if !matchesAlt(head) && matchPattern(rest, tail) {
+				return true
+			}
+		case '*', '+':
+			// After one alternative any number more may follow
+			if matchesAlt(head) && (matchPattern(rest, tail) || i > 0 && matchExtglob('*', alts, rest, tail)) {
+				return true
+			}
+		}
+	}
+	return (op == '?' || op == '*') && matchPattern(rest, s)
+}
+
+// escapeExtglob escapes the opening parenthesis of each extended pattern in
+// pattern, so that it matches literally
+func escapeExtglob(pattern string) string {
+	var b strings.Builder
+	operator := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c == Backslash && i+1 < len(pattern) {
+			b.WriteByte(c)
+			i++
+			b.WriteByte(pattern[i])
+			operator = false
+			continue
+		}
+		if c == '(' && operator {
+			b.WriteByte(Backslash)
+		}
+		b.WriteByte(c)
+		operator = strings.IndexByte("?*+@!", c) >= 0
+	}
+	return b.String()
+}
+
+// extPattern returns pattern with its extended patterns made literal unless
+// extglob is set
+func (s *Shell) extPattern(pattern string) string {
+	if s.options["extglob"] {
+		return pattern
+	}
+	return escapeExtglob(pattern)
+}
+
+// matchBracket matches r against the bracket expression at the start of
+// pattern. It returns whether r matched, the length of the expression, and
+// false for ok if the expression is not terminated.
+func matchBracket(pattern string, r rune) (matched bool, width int, ok bool) {
+	i := 1
+	negate := false
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		negate = true
+		i++
+	}
+
+	for first := true; i < len(pattern); first = false {
+		if pattern[i] == ']' && !first {
+			return matched != negate, i + 1, true
+		}
+
+		if strings.HasPrefix(pattern[i:], "[:") {
+			if end := strings.Index(pattern[i+2:], ":]"); end >= 0 {
+				if matchClass(pattern[i+2:i+2+end], r) {
+					matched = true
+				}
+				i += end + 4
+				continue
+			}
+		}
+
+		if pattern[i] == Backslash && i+1 < len(pattern) {
+			i++
+		}
+		lo, n := utf8.DecodeRuneInString(pattern[i:])
+		i += n
+		hi := lo
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			i++
+			if pattern[i] == Backslash && i+1 < len(pattern) {
+				i++
+			}
+			hi, n = utf8.DecodeRuneInString(pattern[i:])
+			i += n
+		}
+		if lo <= r && r <= hi {
+			matched = true
+		}
+	}
+	return false, 0, false
+}
+
+func matchClass(class string, r rune) bool {
+	switch class {
+	case "alnum":
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	case "alpha":
+		return unicode.IsLetter(r)
+	case "blank":
+		return r == ' ' || r == '\t'
+	case "cntrl":
+		return unicode.IsControl(r)
+	case "digit":
+		return r >= '0' && r <= '9'
+	case "graph":
+		return unicode.IsGraphic(r) && !unicode.IsSpace(r)
+	case "lower":
+		return unicode.IsLower(r)
+	case "print":
+		return unicode.IsPrint(r)
+	case "punct":
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	case "space":
+		return unicode.IsSpace(r)
+	case "upper":
+		return unicode.IsUpper(r)
+	case "xdigit":
+		return strings.ContainsRune("0123456789abcdefABCDEF", r)
+	}
+	return false
+}
+
+// escapePattern escapes the characters that are special in patterns so
+// that s matches only itself
+func escapePattern(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(`*?[]()|\`, s[i]) >= 0 {
+			b.WriteByte(Backslash)
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// expandPathname performs pathname expansion on an expanded word. A word
+// with no unquoted pattern characters, or one that matches nothing, is
+// returned unchanged unless nullglob or failglob is set.
+func (s *Shell) expandPathname(frags []fragment) ([]string, error) {
+	word := joinFragments(frags, false)
+	pattern := s.extPattern(joinFragments(frags, true))
+	if !hasGlobMeta(pattern) {
+		return []string{word}, nil
+	}
+
+	if matches := s.glob(pattern); len(matches) > 0 {
+		return matches, nil
+	}
+	switch {
+	case s.options["failglob"]:
+		return nil, fmt.Errorf("no match: %s", word)
+	case s.options["nullglob"]:
+		return nil, nil
+	}
+	return []string{word}, nil
+}
+
+// glob returns the sorted paths matching pattern. Hidden files only match
+// a component that starts with '.' unless dotglob is set, and a "**"
+// component matches any number of directories when globstar is set.
+func (s *Shell) glob(pattern string) []string {
+	root := ""
+	if strings.HasPrefix(pattern, "/") {
+		root = "/"
+	}
+	var parts []string
+	for _, part := range strings.Split(pattern, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	var matches []string
+	for _, path := range s.globDir(root, parts) {
+		if !strings.HasSuffix(pattern, "/") {
+			matches = append(matches, path)
+		} else if s.isDir(path) {
+			matches = append(matches, path+"/")
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// globDir returns the paths below dir that match the pattern components
+func (s *Shell) globDir(dir string, parts []string) []string {
+	part, rest := parts[0], parts[1:]
+
+	if part == "**" && s.options["globstar"] {
+		descendants := s.walkDir(dir)
+		if len(rest) == 0 {
+			return descendants
+		}
+		matches := s.globDir(dir, rest)
+		for _, path := range descendants {
+			if s.isDir(path) {
+				matches = append(matches, s.globDir(path, rest)...)
+			}
+		}
+		return matches
+	}
+
+	if !hasGlobMeta(part) {
+		path := joinPath(dir, unescapePattern(part))
+		if len(rest) > 0 {
+			if s.isDir(path) {
+				return s.globDir(path, rest)
+			}
+			return nil
+		}
+		if _, err := os.Lstat(s.resolvePath(path)); err == nil {
+			return []string{path}
+		}
+		return nil
+	}
+
+	entries, err := os.ReadDir(s.resolvePath(dir))
+	if err != nil {
+		return nil
+	}
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !s.globVisible(name, part) || !matchPattern(part, name) {
+			continue
+		}
+		path := joinPath(dir, name)
+		if len(rest) == 0 {
+			matches = append(matches, path)
+		} else if s.isDir(path) {
+			matches = append(matches, s.globDir(path, rest)...)
+		}
+	}
+	return matches
+}
+
+// walkDir returns every file and directory below dir for "**", without
+// following symbolic links to directories
+func (s *Shell) walkDir(dir string) []string {
+	entries, err := os.ReadDir(s.resolvePath(dir))
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, entry := range entries {
+		if !s.globVisible(entry.Name(), "") {
+			continue
+		}
+		path := joinPath(dir, entry.Name())
+		paths = append(paths, path)
+		if entry.IsDir() {
+			paths = append(paths, s.walkDir(path)...)
+		}
+	}
+	return paths
+}
+
+// globVisible reports whether a pattern component may match name, hiding
+// dot files from patterns that do not start with a literal '.'
+func (s *Shell) globVisible(name, part string) bool {
+	if !strings.HasPrefix(name, ".") || s.options["dotglob"] {
+		return true
+	}
+	return strings.HasPrefix(part, ".") || strings.HasPrefix(part, `\.`)
+}
+
+// hasGlobMeta reports whether pattern contains an unescaped '*', '?', an
+// extended pattern or a '[' that has a closing ']'
+func hasGlobMeta(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case Backslash:
+			i++
+		case '*', '?':
+			return true
+		case '+', '@', '!':
+			if _, _, ok := cutExtglob(pattern[i:]); ok {
+				return true
+			}
+		case '[':
+			if strings.IndexByte(pattern[i+1:], ']') >= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unescapePattern removes the backslashes from a pattern without
+// special characters
+func unescapePattern(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == Backslash && i+1 < len(pattern) {
+			i++
+		}
+		b.WriteByte(pattern[i])
+	}
+	return b.String()
+}
+
+func joinPath(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	if strings.HasSuffix(dir, "/") {
+		return dir + name
+	}
+	return dir + "/" + name
+}
+
+func (s *Shell) isDir(path string) bool {
+	info, err := os.Stat(s.resolvePath(path))
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := map[string]struct {
+		pattern  string
+		input    string
+		expected bool
+	}{
+		"happy path - literal":                  {pattern: "abc", input: "abc", expected: true},
+		"happy path - star":                     {pattern: "*.go", input: "main.go", expected: true},
+		"happy path - star matches empty":       {pattern: "a*", input: "a", expected: true},
+		"happy path - multiple stars":           {pattern: "*a*b*", input: "xxaxxbxx", expected: true},
+		"happy path - question mark":            {pattern: "?.txt", input: "a.txt", expected: true},
+		"happy path - question mark multibyte":  {pattern: "?", input: "日", expected: true},
+		"happy path - bracket range":            {pattern: "[a-c]x", input: "bx", expected: true},
+		"happy path - bracket negation":         {pattern: "[!a-c]x", input: "dx", expected: true},
+		"happy path - bracket caret negation":   {pattern: "[^0-9]", input: "a", expected: true},
+		"happy path - bracket class":            {pattern: "[[:digit:]][[:alpha:]]", input: "1a", expected: true},
+		"happy path - bracket leading close":    {pattern: "[]a]", input: "]", expected: true},
+		"happy path - escaped star":             {pattern: `a\*`, input: "a*", expected: true},
+		"happy path - unterminated bracket":     {pattern: "[ab", input: "[ab", expected: true},
+		"happy path - extglob one of":           {pattern: "@(a|bc).go", input: "bc.go", expected: true},
+		"happy path - extglob optional":         {pattern: "x?(y)z", input: "xz", expected: true},
+		"happy path - extglob any number":       {pattern: "*(ab|c)", input: "abcab", expected: true},
+		"happy path - extglob at least one":     {pattern: "+([0-9])", input: "123", expected: true},
+		"happy path - extglob none of":          {pattern: "!(*.go)", input: "main.rs", expected: true},
+		"happy path - extglob nested":           {pattern: "@(a|+(b))c", input: "bbc", expected: true},
+		"happy path - extglob unterminated":     {pattern: "@(a", input: "@(a", expected: true},
+		"sad path - literal mismatch":           {pattern: "abc", input: "abd", expected: false},
+		"sad path - extglob one of":             {pattern: "@(a|b)", input: "ab", expected: false},
+		"sad path - extglob at least one":       {pattern: "+(a)", input: "", expected: false},
+		"sad path - extglob none of":            {pattern: "!(*.go)", input: "main.go", expected: false},
+		"sad path - escaped extglob is literal": {pattern: `@\(a\)`, input: "a", expected: false},
+		"sad path - star suffix mismatch":       {pattern: "*.go", input: "main.rs", expected: false},
+		"sad path - question mark needs char":   {pattern: "a?", input: "a", expected: false},
+		"sad path - bracket negation":           {pattern: "[!a-c]", input: "b", expected: false},
+		"sad path - escaped star is literal":    {pattern: `a\*`, input: "ab", expected: false},
+		"sad path - partial match":              {pattern: "ab", input: "abc", expected: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if result := matchPattern(tc.pattern, tc.input); result != tc.expected {
+				t.Errorf("matchPattern(%q, %q): expected %v, got %v", tc.pattern, tc.input, tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestEscapePattern(t *testing.T) {
+	for _, input := range []string{"plain", "*.go", "a?b", "[x]", `back\slash`, "@(a|b)"} {
+		if !matchPattern(escapePattern(input), input) {
+			t.Errorf("expected escaped %q to match itself", input)
+		}
+	}
+	if matchPattern(escapePattern("*"), "anything") {
+		t.Error("expected escaped star to match only itself")
+	}
+}
+
+func TestEscapeExtglob(t *testing.T) {
+	tests := map[string]struct {
+		pattern  string
+		expected string
+	}{
+		"happy path - every operator":  {pattern: "?(a)*(b)+(c)@(d)!(e)", expected: `?\(a)*\(b)+\(c)@\(d)!\(e)`},
+		"edge case - plain group":      {pattern: "(a)", expected: "(a)"},
+		"edge case - escaped operator": {pattern: `\@(a)`, expected: `\@(a)`},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if result := escapeExtglob(tc.pattern); result != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestShell_expandWords_Pathname(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", ".h.go", "d/c.go", "d/e/f.go", "x.txt"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, nil, 0644)
+	}
+
+	tests := map[string]struct {
+		word          string
+		options       []string
+		expected      []string
+		expectedError bool
+	}{
+		"happy path - star":                        {word: "*.go", expected: []string{"a.go", "b.go"}},
+		"happy path - question mark":               {word: "?.txt", expected: []string{"x.txt"}},
+		"happy path - bracket":                     {word: "[b-z].*", expected: []string{"b.go", "x.txt"}},
+		"happy path - directory component":         {word: "*/*.go", expected: []string{"d/c.go"}},
+		"happy path - trailing slash":              {word: "*/", expected: []string{"d/"}},
+		"happy path - explicit dot":                {word: ".*.go", expected: []string{".h.go"}},
+		"happy path - dotglob":                     {word: "*.go", options: []string{"dotglob"}, expected: []string{".h.go", "a.go", "b.go"}},
+		"happy path - globstar":                    {word: "**/*.go", options: []string{"globstar"}, expected: []string{"a.go", "b.go", "d/c.go", "d/e/f.go"}},
+		"happy path - globstar alone":              {word: "d/**", options: []string{"globstar"}, expected: []string{"d/c.go", "d/e", "d/e/f.go"}},
+		"edge case - double star without globstar": {word: "**/*.go", expected: []string{"d/c.go"}},
+		"happy path - quoted pattern":              {word: `"*.go"`, expected: []string{"*.go"}},
+		"happy path - escaped pattern":             {word: `\*.go`, expected: []string{"*.go"}},
+		"edge case - no match kept":                {word: "*.rs", expected: []string{"*.rs"}},
+		"edge case - nullglob":                     {word: "*.rs", options: []string{"nullglob"}, expected: nil},
+		"sad path - failglob":                      {word: "*.rs", options: []string{"failglob"}, expectedError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			for _, option := range tc.options {
+				shell.options[option] = true
+			}
+
+			prefix := dir + "/"
+			result, err := shell.expandWords([]string{prefix + tc.word})
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("expected an error, got %v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(result) != len(tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, result)
+			}
+			for i := range result {
+				if strings.TrimPrefix(result[i], prefix) != tc.expected[i] {
+					t.Errorf("expected %v, got %v", tc.expected, result)
+				}
+			}
+		})
+	}
+}
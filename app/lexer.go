@@ -0,0 +1,488 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokIONumber
+	tokOperator
+	tokNewline
+	tokArith
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// errIncomplete reports that the input ended inside an unfinished construct
+var errIncomplete = errors.New("syntax error: unexpected end of file")
+
+// operators lists the control and redirection operators, longest first so
+// that the first prefix match is the longest one
+var operators = []string{
+	"&>>", "<<<", "<<-", ";;&",
+	"<<",
+	"&&", "||", ">>", ">|", "<>", "<&", ">&", "&>", ";;", ";&",
+	"|", "&", ";", "(", ")", "<", ">",
+}
+
+// lexer splits shell input into words and operators. Words keep their quotes
+// and escapes; removing them is left to the expansion phase.
+type lexer struct {
+	src string
+	pos int
+	// start is the offset of the last token returned
+	start int
+
+	// hereOp is the here-document operator just lexed, whose delimiter is
+	// the next word; hereDoc is the here-document that word started, and
+	// pending those whose bodies start after the next newline
+	hereOp  string
+	hereDoc *HereDoc
+	pending []*HereDoc
+}
+
+func (l *lexer) next() (token, error) {
+	hereOp := l.hereOp
+	l.hereOp = ""
+
+	l.skipBlanks()
+	l.start = l.pos
+	if l.pos >= len(l.src) {
+		if len(l.pending) > 0 {
+			return token{}, errIncomplete
+		}
+		return token{kind: tokEOF}, nil
+	}
+
+	c := l.src[l.pos]
+	if c == '\n' {
+		l.pos++
+		if err := l.readHereDocs(); err != nil {
+			return token{}, err
+		}
+		return token{kind: tokNewline, text: "\n"}, nil
+	}
+
+	if isDigit(c) {
+		end := l.pos
+		for end < len(l.src) && isDigit(l.src[end]) {
+			end++
+		}
+		if end < len(l.src) && (l.src[end] == '<' || l.src[end] == '>') {
+			text := l.src[l.pos:end]
+			l.pos = end
+			return token{kind: tokIONumber, text: text}, nil
+		}
+	}
+
+	if strings.HasPrefix(l.src[l.pos:], "((") {
+		end, err := l.arithEnd()
+		if err != nil {
+			return token{}, err
+		}
+		if end >= 0 {
+			text := l.src[l.pos+2 : end]
+			l.pos = end + 2
+			return token{kind: tokArith, text: text}, nil
+		}
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[l.pos:], op) && !l.atProcessSubstitution() {
+			l.pos += len(op)
+			if op == "<<" || op == "<<-" {
+				l.hereOp = op
+			}
+			return token{kind: tokOperator, text: op}, nil
+		}
+	}
+
+	tok, err := l.word()
+	if err == nil && hereOp != "" {
+		l.hereDoc = &HereDoc{
+			Delim:     removeQuotes(tok.text),
+			StripTabs: hereOp == "<<-",
+			Quoted:    strings.ContainsAny(tok.text, `'"\`),
+		}
+		l.pending = append(l.pending, l.hereDoc)
+	}
+	return tok, err
+}
+
+// readHereDocs reads the bodies of the pending here-documents from the
+// lines starting at l.pos, each ending at a line holding only its delimiter
+func (l *lexer) readHereDocs() error {
+	for _, doc := range l.pending {
+		var body strings.Builder
+		for {
+			if l.pos >= len(l.src) {
+				return errIncomplete
+			}
+			line, rest, found := strings.Cut(l.src[l.pos:], "\n")
+			if doc.StripTabs {
+				line = strings.TrimLeft(line, "\t")
+			}
+			l.pos = len(l.src) - len(rest)
+			if line == doc.Delim {
+				break
+			}
+			body.WriteString(line + "\n")
+			if !found {
+				return errIncomplete
+			}
+		}
+		doc.Body = body.String()
+	}
+	l.pending = nil
+	return nil
+}
+
+// arithEnd returns the index of the "))" closing the arithmetic command
+// whose "((" is at l.pos, or -1 if the parentheses close separately and so
+// do not form one
+func (l *lexer) arithEnd() (int, error) {
+	sub := &lexer{src: l.src, pos: l.pos + 2}
+	depth := 0
+	for sub.pos < len(sub.src) {
+		switch sub.src[sub.pos] {
+		case '(':
+			depth++
+			sub.pos++
+		case ')':
+			if depth > 0 {
+				depth--
+				sub.pos++
+				continue
+			}
+			if strings.HasPrefix(sub.src[sub.pos:], "))") {
+				return sub.pos, nil
+			}
+			return -1, nil
+		case Backslash:
+			sub.pos += 2
+		case SingleQuote:
+			end := strings.IndexByte(sub.src[sub.pos+1:], SingleQuote)
+			if end < 0 {
+				return 0, errIncomplete
+			}
+			sub.pos += end + 2
+		case DoubleQuote:
+			if err := sub.skipDoubleQuoted(); err != nil {
+				return 0, err
+			}
+		case '$':
+			if err := sub.skipDollar(); err != nil {
+				return 0, err
+			}
+		case Backquote:
+			if err := sub.skipBackquote(); err != nil {
+				return 0, err
+			}
+		default:
+			sub.pos++
+		}
+	}
+	return 0, errIncomplete
+}
+
+// skipBlanks skips spaces, tabs, escaped newlines and comments
+func (l *lexer) skipBlanks() {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == ' ' || c == '\t':
+			l.pos++
+		case c == Backslash && l.pos+1 < len(l.src) && l.src[l.pos+1] == '\n':
+			l.pos += 2
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// atProcessSubstitution reports whether a "<(" or ">(" process
+// substitution starts at l.pos
+func (l *lexer) atProcessSubstitution() bool {
+	return strings.HasPrefix(l.src[l.pos:], "<(") || strings.HasPrefix(l.src[l.pos:], ">(")
+}
+
+func (l *lexer) word() (token, error) {
+	start := l.pos
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case l.atProcessSubstitution():
+			l.pos++
+			if err := l.skipSubstitution(); err != nil {
+				return token{}, err
+			}
+		case c == '(' && strings.HasSuffix(l.src[start:l.pos], "=") && isAssignment(l.src[start:l.pos]):
+			// The parenthesised values of an array assignment: a=(x y)
+			if err := l.skipParens(); err != nil {
+				return token{}, err
+			}
+		case c == '(' && l.pos > start && strings.IndexByte("?*+@!", l.src[l.pos-1]) >= 0:
+			// The parenthesised alternatives of an extended pattern: @(a|b)
+			if err := l.skipParens(); err != nil {
+				return token{}, err
+			}
+		case isMetachar(c):
+			return token{kind: tokWord, text: l.src[start:l.pos]}, nil
+		case c == Backslash:
+			if l.pos+1 >= len(l.src) {
+				return token{}, errIncomplete
+			}
+			l.pos += 2
+		case c == SingleQuote:
+			end := strings.IndexByte(l.src[l.pos+1:], SingleQuote)
+			if end < 0 {
+				return token{}, errIncomplete
+			}
+			l.pos += end + 2
+		case c == DoubleQuote:
+			if err := l.skipDoubleQuoted(); err != nil {
+				return token{}, err
+			}
+		case c == '$':
+			if err := l.skipDollar(); err != nil {
+				return token{}, err
+			}
+		case c == Backquote:
+			if err := l.skipBackquote(); err != nil {
+				return token{}, err
+			}
+		default:
+			l.pos++
+		}
+	}
+	return token{kind: tokWord, text: l.src[start:l.pos]}, nil
+}
+
+// regexWord lexes the word after "=~" in a [[ ]] command. Parentheses and
+// '|' belong to the regular expression, so it runs to the next blank that
+// is outside parentheses and quotes.
+func (l *lexer) regexWord() (token, error) {
+	l.skipBlanks()
+	l.start = l.pos
+	depth := 0
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if depth == 0 {
+				return token{kind: tokWord, text: l.src[l.start:l.pos]}, nil
+			}
+			l.pos++
+		case c == '(':
+			depth++
+			l.pos++
+		case c == ')':
+			depth--
+			l.pos++
+		case c == Backslash:
+			if l.pos+1 >= len(l.src) {
+				return token{}, errIncomplete
+			}
+			l.pos += 2
+		case c == SingleQuote:
+			end := strings.IndexByte(l.src[l.pos+1:], SingleQuote)
+			if end < 0 {
+				return token{}, errIncomplete
+			}
+			l.pos += end + 2
+		case c == DoubleQuote:
+			if err := l.skipDoubleQuoted(); err != nil {
+				return token{}, err
+			}
+		case c == '$':
+			if err := l.skipDollar(); err != nil {
+				return token{}, err
+			}
+		default:
+			l.pos++
+		}
+	}
+	return token{kind: tokWord, text: l.src[l.start:l.pos]}, nil
+}
+
+// skipParens advances past a parenthesised group starting at l.pos
+func (l *lexer) skipParens() error {
+	depth := 0
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case '(':
+			depth++
+			l.pos++
+		case ')':
+			depth--
+			l.pos++
+			if depth == 0 {
+				return nil
+			}
+		case Backslash:
+			l.pos += 2
+		case SingleQuote:
+			end := strings.IndexByte(l.src[l.pos+1:], SingleQuote)
+			if end < 0 {
+				return errIncomplete
+			}
+			l.pos += end + 2
+		case DoubleQuote:
+			if err := l.skipDoubleQuoted(); err != nil {
+				return err
+			}
+		case '$':
+			if err := l.skipDollar(); err != nil {
+				return err
+			}
+		case Backquote:
+			if err := l.skipBackquote(); err != nil {
+				return err
+			}
+		default:
+			l.pos++
+		}
+	}
+	return errIncomplete
+}
+
+// skipDollar advances past a '$' and, for "${...}", "$((...))" and
+// "$(...)", the braced parameter, the arithmetic expression or the command
+// substitution
+func (l *lexer) skipDollar() error {
+	l.pos++
+	if strings.HasPrefix(l.src[l.pos:], "((") {
+		// An expression is not made of shell tokens: "<<" is a shift
+		return l.skipParens()
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '(' {
+		return l.skipSubstitution()
+	}
+	if l.pos >= len(l.src) || l.src[l.pos] != '{' {
+		return nil
+	}
+
+	l.pos++
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case '}':
+			l.pos++
+			return nil
+		case Backslash:
+			l.pos += 2
+		case SingleQuote:
+			end := strings.IndexByte(l.src[l.pos+1:], SingleQuote)
+			if end < 0 {
+				return errIncomplete
+			}
+			l.pos += end + 2
+		case DoubleQuote:
+			if err := l.skipDoubleQuoted(); err != nil {
+				return err
+			}
+		case '$':
+			if err := l.skipDollar(); err != nil {
+				return err
+			}
+		case Backquote:
+			if err := l.skipBackquote(); err != nil {
+				return err
+			}
+		default:
+			l.pos++
+		}
+	}
+	return errIncomplete
+}
+
+// skipSubstitution advances past the "(...)" of a command substitution
+// starting at l.pos. The commands inside are parsed, so that quotes, nested
+// substitutions and the ')' after a case pattern are matched properly.
+func (l *lexer) skipSubstitution() error {
+	p := &parser{lex: lexer{src: l.src, pos: l.pos + 1}}
+	if err := p.advance(); err != nil {
+		return err
+	}
+	if _, err := p.parseList(); err != nil {
+		return err
+	}
+	if !p.isOp(")") {
+		return p.unexpected()
+	}
+	l.pos = p.lex.pos
+	return nil
+}
+
+// skipBackquote advances past a `...` command substitution starting at l.pos
+func (l *lexer) skipBackquote() error {
+	for i := l.pos + 1; i < len(l.src); i++ {
+		switch l.src[i] {
+		case Backslash:
+			i++
+		case Backquote:
+			l.pos = i + 1
+			return nil
+		}
+	}
+	return errIncomplete
+}
+
+// skipDoubleQuoted advances past a double-quoted string starting at l.pos
+func (l *lexer) skipDoubleQuoted() error {
+	l.pos++
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case Backslash:
+			l.pos += 2
+		case DoubleQuote:
+			l.pos++
+			return nil
+		case '$':
+			if err := l.skipDollar(); err != nil {
+				return err
+			}
+		case Backquote:
+			if err := l.skipBackquote(); err != nil {
+				return err
+			}
+		default:
+			l.pos++
+		}
+	}
+	return errIncomplete
+}
+
+func isMetachar(c byte) bool {
+	return strings.IndexByte(" \t\n;&|()<>", c) >= 0
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// lexWords returns the words of input, treating operators as literal words
+func lexWords(input string) []string {
+	l := &lexer{src: input}
+	var words []string
+	for {
+		tok, err := l.next()
+		if err != nil || tok.kind == tokEOF {
+			return words
+		}
+		if tok.kind != tokNewline {
+			words = append(words, tok.text)
+		}
+	}
+}
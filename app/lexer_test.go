@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLexer_next(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected []token
+	}{
+		"happy path - words": {
+			input:    "echo hello world",
+			expected: []token{{tokWord, "echo"}, {tokWord, "hello"}, {tokWord, "world"}},
+		},
+		"happy path - operators without spaces": {
+			input: "ls|wc&&echo a>b",
+			expected: []token{
+				{tokWord, "ls"}, {tokOperator, "|"}, {tokWord, "wc"}, {tokOperator, "&&"},
+				{tokWord, "echo"}, {tokWord, "a"}, {tokOperator, ">"}, {tokWord, "b"},
+			},
+		},
+		"happy path - quotes are kept in words": {
+			input:    `echo "a|b" 'c;d'`,
+			expected: []token{{tokWord, "echo"}, {tokWord, `"a|b"`}, {tokWord, `'c;d'`}},
+		},
+		"happy path - io number": {
+			input:    "cmd 2>>err",
+			expected: []token{{tokWord, "cmd"}, {tokIONumber, "2"}, {tokOperator, ">>"}, {tokWord, "err"}},
+		},
+		"happy path - redirection operators": {
+			input: "cmd 2>&1 <>f &>>g <&-",
+			expected: []token{
+				{tokWord, "cmd"}, {tokIONumber, "2"}, {tokOperator, ">&"}, {tokWord, "1"},
+				{tokOperator, "<>"}, {tokWord, "f"}, {tokOperator, "&>>"}, {tokWord, "g"}, {tokOperator, "<&"}, {tokWord, "-"},
+			},
+		},
+		"happy path - case terminators": {
+			input: "a) x;; b) y;& c) z;;&",
+			expected: []token{
+				{tokWord, "a"}, {tokOperator, ")"}, {tokWord, "x"}, {tokOperator, ";;"}, {tokWord, "b"}, {tokOperator, ")"},
+				{tokWord, "y"}, {tokOperator, ";&"}, {tokWord, "c"}, {tokOperator, ")"}, {tokWord, "z"}, {tokOperator, ";;&"},
+			},
+		},
+		"happy path - here-document body is skipped": {
+			input: "cat <<EOF; echo a\n$x (\nEOF\necho b",
+			expected: []token{
+				{tokWord, "cat"}, {tokOperator, "<<"}, {tokWord, "EOF"}, {tokOperator, ";"}, {tokWord, "echo"},
+				{tokWord, "a"}, {tokNewline, "\n"}, {tokWord, "echo"}, {tokWord, "b"},
+			},
+		},
+		"happy path - process substitution": {
+			input:    "diff <(sort a) >(cat) x<(ls)",
+			expected: []token{{tokWord, "diff"}, {tokWord, "<(sort a)"}, {tokWord, ">(cat)"}, {tokWord, "x<(ls)"}},
+		},
+		"happy path - digits that are not an io number": {
+			input:    "echo 2 > f",
+			expected: []token{{tokWord, "echo"}, {tokWord, "2"}, {tokOperator, ">"}, {tokWord, "f"}},
+		},
+		"happy path - command substitution": {
+			input:    `echo a$(echo "x)"; (y); $(z))b c`,
+			expected: []token{{tokWord, "echo"}, {tokWord, `a$(echo "x)"; (y); $(z))b`}, {tokWord, "c"}},
+		},
+		"happy path - case inside command substitution": {
+			input:    "echo $(case x in x) echo hi;; (y|z) ;; esac) c",
+			expected: []token{{tokWord, "echo"}, {tokWord, "$(case x in x) echo hi;; (y|z) ;; esac)"}, {tokWord, "c"}},
+		},
+		"happy path - extended pattern": {
+			input:    "echo @(a|b)*.go !(x) c",
+			expected: []token{{tokWord, "echo"}, {tokWord, "@(a|b)*.go"}, {tokWord, "!(x)"}, {tokWord, "c"}},
+		},
+		"happy path - backquotes": {
+			input:    "echo `echo a | b` c",
+			expected: []token{{tokWord, "echo"}, {tokWord, "`echo a | b`"}, {tokWord, "c"}},
+		},
+		"happy path - arithmetic command": {
+			input:    "(( a < (b) )) && echo",
+			expected: []token{{tokArith, " a < (b) "}, {tokOperator, "&&"}, {tokWord, "echo"}},
+		},
+		"happy path - separate parentheses are operators": {
+			input:    "((a) )",
+			expected: []token{{tokOperator, "("}, {tokOperator, "("}, {tokWord, "a"}, {tokOperator, ")"}, {tokOperator, ")"}},
+		},
+		"happy path - arithmetic expansion": {
+			input:    "echo $((1 << 2))",
+			expected: []token{{tokWord, "echo"}, {tokWord, "$((1 << 2))"}},
+		},
+		"happy path - array assignment": {
+			input:    "a=(x 'y z') b",
+			expected: []token{{tokWord, "a=(x 'y z')"}, {tokWord, "b"}},
+		},
+		"happy path - newline and comment": {
+			input:    "echo a # comment\necho b",
+			expected: []token{{tokWord, "echo"}, {tokWord, "a"}, {tokNewline, "\n"}, {tokWord, "echo"}, {tokWord, "b"}},
+		},
+		"happy path - escaped operator": {
+			input:    `echo a\;b`,
+			expected: []token{{tokWord, "echo"}, {tokWord, `a\;b`}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			l := &lexer{src: tc.input}
+			var result []token
+			for {
+				tok, err := l.next()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if tok.kind == tokEOF {
+					break
+				}
+				result = append(result, tok)
+			}
+			if len(result) != len(tc.expected) {
+				t.Fatalf("expected %d tokens, got %d: %v", len(tc.expected), len(result), result)
+			}
+			for i := range result {
+				if result[i] != tc.expected[i] {
+					t.Errorf("token[%d]: expected %v, got %v", i, tc.expected[i], result[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLexer_next_Incomplete(t *testing.T) {
+	tests := map[string]struct {
+		input string
+	}{
+		"sad path - unterminated single quote":  {input: "echo 'abc"},
+		"sad path - unterminated double quote":  {input: `echo "abc`},
+		"sad path - trailing backslash":         {input: `echo abc\`},
+		"sad path - unterminated substitution":  {input: "echo $(echo a"},
+		"sad path - unterminated backquote":     {input: "echo `echo a"},
+		"sad path - unterminated arithmetic":    {input: "(( 1 + 2"},
+		"sad path - here-document without body": {input: "cat <<EOF"},
+		"sad path - unterminated here-document": {input: "cat <<EOF\nline\nEO"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			l := &lexer{src: tc.input}
+			var err error
+			for err == nil {
+				var tok token
+				if tok, err = l.next(); tok.kind == tokEOF {
+					break
+				}
+			}
+			if !errors.Is(err, errIncomplete) {
+				t.Errorf("expected errIncomplete, got %v", err)
+			}
+		})
+	}
+}
@@ -1,6 +1,114 @@
 package main
 
-func main() {
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/chzyer/readline"
+)
+
+// invocation describes how the shell was started
+type invocation struct {
+	// command is the -c command string
+	command    string
+	hasCommand bool
+	// script is the path of the script to run, empty when reading stdin
+	script      string
+	stdin       bool
+	interactive bool
+	arg0        string
+	params      []string
+}
+
+// parseInvocation parses the shell's command-line arguments, args[0] being
+// the name it was started with
+func parseInvocation(args []string) (invocation, error) {
+	inv := invocation{arg0: args[0]}
+	args = args[1:]
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" || arg == "-" {
+			break
+		}
+		for _, flag := range arg[1:] {
+			switch flag {
+			case 'c':
+				inv.hasCommand = true
+			case 's':
+				inv.stdin = true
+			case 'i':
+				inv.interactive = true
+			default:
+				return inv, fmt.Errorf("-%c: invalid option", flag)
+			}
+		}
+	}
+
+	switch {
+	case inv.hasCommand:
+		if len(args) == 0 {
+			return inv, errors.New("-c: option requires an argument")
+		}
+		inv.command, args = args[0], args[1:]
+		if len(args) > 0 {
+			inv.arg0, args = args[0], args[1:]
+		}
+	case inv.stdin || len(args) == 0:
+		inv.stdin = true
+	default:
+		inv.script, inv.arg0, args = args[0], args[0], args[1:]
+	}
+	if inv.interactive && !inv.stdin {
+		return inv, errors.New("-i: cannot be used with -c or a script")
+	}
+	inv.params = args
+	return inv, nil
+}
+
+// run starts the shell as described by args and returns its exit status
+func run(args []string) int {
+	inv, err := parseInvocation(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", args[0], err)
+		return 2
+	}
+
 	shell := NewShell()
-	shell.Run()
+	shell.arg0 = inv.arg0
+	shell.params = inv.params
+
+	switch {
+	case inv.hasCommand:
+		return shell.runScript(strings.NewReader(inv.command))
+	case inv.script != "":
+		f, err := os.Open(inv.script)
+		if err == nil {
+			defer f.Close()
+			if info, statErr := f.Stat(); statErr == nil && info.IsDir() {
+				err = syscall.EISDIR
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s: %s\n", args[0], inv.script, strerror(err))
+			if errors.Is(err, os.ErrNotExist) {
+				return 127
+			}
+			return 126
+		}
+		return shell.runScript(bufio.NewReader(f))
+	case inv.interactive || readline.IsTerminal(int(os.Stdin.Fd())) && readline.IsTerminal(int(os.Stderr.Fd())):
+		shell.Run()
+		return shell.lastStatus
+	default:
+		return shell.runScript(os.Stdin)
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args))
 }
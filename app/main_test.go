@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseInvocation(t *testing.T) {
+	tests := map[string]struct {
+		args        []string
+		expected    invocation
+		expectedErr string
+	}{
+		"happy path - no arguments reads stdin": {
+			args:     []string{"gosh"},
+			expected: invocation{stdin: true, arg0: "gosh", params: []string{}},
+		},
+		"happy path - script with arguments": {
+			args:     []string{"gosh", "script.sh", "a", "-b"},
+			expected: invocation{script: "script.sh", arg0: "script.sh", params: []string{"a", "-b"}},
+		},
+		"happy path - command string": {
+			args:     []string{"gosh", "-c", "echo $0 $1", "name", "one"},
+			expected: invocation{command: "echo $0 $1", hasCommand: true, arg0: "name", params: []string{"one"}},
+		},
+		"happy path - command string without a name": {
+			args:     []string{"gosh", "-c", "echo hi"},
+			expected: invocation{command: "echo hi", hasCommand: true, arg0: "gosh", params: []string{}},
+		},
+		"happy path - stdin with arguments": {
+			args:     []string{"gosh", "-s", "a", "b"},
+			expected: invocation{stdin: true, arg0: "gosh", params: []string{"a", "b"}},
+		},
+		"happy path - combined flags": {
+			args:     []string{"gosh", "-is"},
+			expected: invocation{stdin: true, interactive: true, arg0: "gosh", params: []string{}},
+		},
+		"edge case - double dash ends options": {
+			args:     []string{"gosh", "--", "-script"},
+			expected: invocation{script: "-script", arg0: "-script", params: []string{}},
+		},
+		"sad path - unknown option": {
+			args:        []string{"gosh", "-x"},
+			expectedErr: "-x: invalid option",
+		},
+		"sad path - command string missing": {
+			args:        []string{"gosh", "-c"},
+			expectedErr: "-c: option requires an argument",
+		},
+		"sad path - interactive command string": {
+			args:        []string{"gosh", "-i", "-c", "echo hi"},
+			expectedErr: "-i: cannot be used with -c or a script",
+		},
+		"sad path - interactive script": {
+			args:        []string{"gosh", "-i", "script.sh"},
+			expectedErr: "-i: cannot be used with -c or a script",
+		},
+		"happy path - interactive with arguments": {
+			args:     []string{"gosh", "-i", "-s", "a"},
+			expected: invocation{stdin: true, interactive: true, arg0: "gosh", params: []string{"a"}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := parseInvocation(tc.args)
+			if tc.expectedErr != "" {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Errorf("expected error %q, got %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestRun_UnreadableScript(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := map[string]struct {
+		script         string
+		expectedErr    string
+		expectedStatus int
+	}{
+		"sad path - missing script": {
+			script:         filepath.Join(dir, "missing"),
+			expectedErr:    "No such file or directory",
+			expectedStatus: 127,
+		},
+		"sad path - directory": {
+			script:         dir,
+			expectedErr:    "Is a directory",
+			expectedStatus: 126,
+		},
+		"sad path - not a directory": {
+			script:         filepath.Join(os.Args[0], "script.sh"),
+			expectedErr:    "Not a directory",
+			expectedStatus: 126,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			oldStderr := os.Stderr
+			r, w, _ := os.Pipe()
+			os.Stderr = w
+			status := run([]string{"gosh", tc.script})
+			w.Close()
+			os.Stderr = oldStderr
+
+			var buf bytes.Buffer
+			io.Copy(&buf, r)
+
+			if status != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, status)
+			}
+			if expected := "gosh: " + tc.script + ": " + tc.expectedErr + "\n"; buf.String() != expected {
+				t.Errorf("expected stderr %q, got %q", expected, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,712 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type parser struct {
+	lex lexer
+	tok token
+	// end is the offset just past the last token consumed
+	end int
+}
+
+// parse turns src into a syntax tree
+func parse(src string) (*List, error) {
+	p := &parser{lex: lexer{src: src}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	list, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.unexpected()
+	}
+	return list, nil
+}
+
+func (p *parser) advance() error {
+	p.end = p.lex.pos
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) isOp(op string) bool {
+	return p.tok.kind == tokOperator && p.tok.text == op
+}
+
+// redirectOps maps each redirection operator to the file descriptor it
+// applies to when no number precedes it
+var redirectOps = map[string]int{
+	"<": 0, "<>": 0, "<&": 0, "<<": 0, "<<-": 0, "<<<": 0,
+	">": 1, ">|": 1, ">>": 1, ">&": 1, "&>": 1, "&>>": 1,
+}
+
+func (p *parser) isRedirect() bool {
+	if p.tok.kind == tokIONumber {
+		return true
+	}
+	_, ok := redirectOps[p.tok.text]
+	return ok && p.tok.kind == tokOperator
+}
+
+func (p *parser) unexpected() error {
+	switch p.tok.kind {
+	case tokEOF:
+		return errIncomplete
+	case tokNewline:
+		return fmt.Errorf("syntax error near unexpected token `newline'")
+	}
+	return fmt.Errorf("syntax error near unexpected token `%s'", p.tok.text)
+}
+
+func (p *parser) skipNewlines() error {
+	for p.tok.kind == tokNewline {
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isWord reports whether the current token is the unquoted word w, as
+// reserved words like "{" must be
+func (p *parser) isWord(w string) bool {
+	return p.tok.kind == tokWord && p.tok.text == w
+}
+
+// closers are the reserved words that end the list inside a compound
+// command; they are only recognized where a command would start
+var closers = map[string]bool{
+	"}": true, "then": true, "elif": true, "else": true, "fi": true, "do": true, "done": true, "esac": true,
+}
+
+func (p *parser) startsCommand() bool {
+	if p.tok.kind == tokWord && closers[p.tok.text] {
+		return false
+	}
+	return p.tok.kind == tokWord || p.tok.kind == tokArith || p.isOp("(") || p.isRedirect()
+}
+
+func (p *parser) parseList() (*List, error) {
+	list := &List{}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+	for p.startsCommand() {
+		item, err := p.parseAndOr()
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, item)
+
+		if !p.isOp(";") && p.tok.kind != tokNewline {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+func (p *parser) parseAndOr() (*AndOr, error) {
+	pipeline, err := p.parsePipeline()
+	if err != nil {
+		return nil, err
+	}
+	andOr := &AndOr{Pipelines: []*Pipeline{pipeline}}
+
+	for p.isOp("&&") || p.isOp("||") {
+		andOr.Ops = append(andOr.Ops, p.tok.text)
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+		if pipeline, err = p.parsePipeline(); err != nil {
+			return nil, err
+		}
+		andOr.Pipelines = append(andOr.Pipelines, pipeline)
+	}
+	return andOr, nil
+}
+
+func (p *parser) parsePipeline() (*Pipeline, error) {
+	negate := false
+	for p.tok.kind == tokWord && p.tok.text == "!" {
+		negate = !negate
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	head, err := p.parseCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	tail := head
+	for p.isOp("|") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+		next, err := p.parseCommand()
+		if err != nil {
+			return nil, err
+		}
+		tail.Next = next
+		tail = next
+	}
+	return &Pipeline{Negate: negate, Cmd: head}, nil
+}
+
+func (p *parser) parseCommand() (*Command, error) {
+	var compound Node
+	switch {
+	case p.tok.kind == tokArith:
+		compound = &ArithCommand{Expr: p.tok.text}
+	case p.isOp("("):
+		body, err := p.parseBody(")")
+		if err != nil {
+			return nil, err
+		}
+		compound = &Subshell{Body: body}
+	case p.isWord("{"):
+		body, err := p.parseBody("}")
+		if err != nil {
+			return nil, err
+		}
+		compound = &Group{Body: body}
+	case p.isWord("if"):
+		n, err := p.parseIf()
+		if err != nil {
+			return nil, err
+		}
+		compound = n
+	case p.isWord("function"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokWord {
+			return nil, p.unexpected()
+		}
+		name := p.tok.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		return p.parseFunction(name)
+	case p.isWord("[["):
+		n, err := p.parseCond()
+		if err != nil {
+			return nil, err
+		}
+		compound = n
+	case p.isWord("case"):
+		n, err := p.parseCase()
+		if err != nil {
+			return nil, err
+		}
+		compound = n
+	case p.isWord("for"):
+		n, err := p.parseFor()
+		if err != nil {
+			return nil, err
+		}
+		compound = n
+	case p.isWord("while") || p.isWord("until"):
+		until := p.isWord("until")
+		cond, err := p.parseBody("do")
+		if err != nil {
+			return nil, err
+		}
+		body, err := p.parseBody("done")
+		if err != nil {
+			return nil, err
+		}
+		compound = &While{Cond: cond, Body: body, Until: until}
+	}
+	if compound != nil {
+		// The current token is the one that ends the compound command
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		cmd := &Command{Compound: compound}
+		for p.isRedirect() {
+			if err := p.parseRedirect(cmd); err != nil {
+				return nil, err
+			}
+		}
+		return cmd, nil
+	}
+
+	cmd := &Command{}
+	hasRedirect := false
+
+	for {
+		if p.tok.kind == tokWord {
+			if len(cmd.Words) == 0 && isAssignment(p.tok.text) {
+				cmd.Assigns = append(cmd.Assigns, p.tok.text)
+			} else {
+				cmd.Words = append(cmd.Words, p.tok.text)
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !p.isRedirect() {
+			break
+		}
+		if err := p.parseRedirect(cmd); err != nil {
+			return nil, err
+		}
+		hasRedirect = true
+	}
+
+	if len(cmd.Words) == 0 && len(cmd.Assigns) == 0 && !hasRedirect {
+		return nil, p.unexpected()
+	}
+	if len(cmd.Words) == 1 && len(cmd.Assigns) == 0 && !hasRedirect && p.isOp("(") {
+		return p.parseFunction(cmd.Words[0])
+	}
+
+	if len(cmd.Words) > 0 {
+		cmd.Name = removeQuotes(cmd.Words[0])
+		cmd.Args = make([]string, 0, len(cmd.Words)-1)
+		for _, word := range cmd.Words[1:] {
+			cmd.Args = append(cmd.Args, removeQuotes(word))
+		}
+	}
+	return cmd, nil
+}
+
+// startsCompound reports whether the current token starts a compound
+// command, as the body of a function must
+func (p *parser) startsCompound() bool {
+	if p.tok.kind == tokArith || p.isOp("(") {
+		return true
+	}
+	for _, w := range []string{"{", "[[", "if", "for", "while", "until", "case"} {
+		if p.isWord(w) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFunction parses the rest of a function definition after its name:
+// the "()", which only the "function" form may leave out, and the compound
+// command that is the body
+func (p *parser) parseFunction(name string) (*Command, error) {
+	if strings.ContainsAny(name, "'\"\\$`=") {
+		return nil, fmt.Errorf("`%s': not a valid identifier", name)
+	}
+	if p.isOp("(") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, p.unexpected()
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+	if !p.startsCompound() {
+		return nil, p.unexpected()
+	}
+
+	start := p.lex.start
+	body, err := p.parseCommand()
+	if err != nil {
+		return nil, err
+	}
+	def := &FuncDef{Name: name, Body: body, Source: p.lex.src[start:p.end]}
+	return &Command{Compound: def}, nil
+}
+
+// parseBody parses a list inside a compound command, from the token before
+// it to one of the tokens that may close it, which is left current
+func (p *parser) parseBody(closers ...string) (*List, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	list, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Items) > 0 {
+		for _, closer := range closers {
+			if p.isOp(closer) || p.isWord(closer) {
+				return list, nil
+			}
+		}
+	}
+	return nil, p.unexpected()
+}
+
+// parseIf parses an if command from "if" up to its closing "fi"
+func (p *parser) parseIf() (*If, error) {
+	n := &If{}
+	for len(n.Clauses) == 0 || p.isWord("elif") {
+		cond, err := p.parseBody("then")
+		if err != nil {
+			return nil, err
+		}
+		body, err := p.parseBody("elif", "else", "fi")
+		if err != nil {
+			return nil, err
+		}
+		n.Clauses = append(n.Clauses, IfClause{Cond: cond, Body: body})
+	}
+	if p.isWord("else") {
+		body, err := p.parseBody("fi")
+		if err != nil {
+			return nil, err
+		}
+		n.Else = body
+	}
+	return n, nil
+}
+
+// parseFor parses a for loop from "for" up to its closing "done": either
+// "for name [in words]" or "for (( init; cond; step ))". Without "in" the
+// loop runs over the positional parameters.
+func (p *parser) parseFor() (Node, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	var loop Node
+	switch {
+	case p.tok.kind == tokArith:
+		parts := strings.Split(p.tok.text, ";")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("syntax error: arithmetic expression required")
+		}
+		loop = &ArithFor{Init: parts[0], Cond: parts[1], Step: parts[2]}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	case p.tok.kind == tokWord:
+		if !isName(p.tok.text) {
+			return nil, fmt.Errorf("`%s': not a valid identifier", p.tok.text)
+		}
+		n := &For{Var: p.tok.text, Words: []string{`"$@"`}}
+		loop = n
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+		if p.isWord("in") {
+			n.Words = []string{}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			for p.tok.kind == tokWord {
+				n.Words = append(n.Words, p.tok.text)
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+			}
+			if !p.isOp(";") && p.tok.kind != tokNewline {
+				return nil, p.unexpected()
+			}
+		}
+	default:
+		return nil, p.unexpected()
+	}
+
+	if p.isOp(";") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+	if !p.isWord("do") {
+		return nil, p.unexpected()
+	}
+	body, err := p.parseBody("done")
+	if err != nil {
+		return nil, err
+	}
+	switch n := loop.(type) {
+	case *For:
+		n.Body = body
+	case *ArithFor:
+		n.Body = body
+	}
+	return loop, nil
+}
+
+// parseCase parses a case command from "case" up to its closing "esac"
+func (p *parser) parseCase() (*Case, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokWord {
+		return nil, p.unexpected()
+	}
+	n := &Case{Word: p.tok.text}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+	if !p.isWord("in") {
+		return nil, p.unexpected()
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+
+	for !p.isWord("esac") {
+		item := CaseItem{Term: ";;"}
+		if p.isOp("(") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		for {
+			if p.tok.kind != tokWord {
+				return nil, p.unexpected()
+			}
+			item.Patterns = append(item.Patterns, p.tok.text)
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if !p.isOp("|") {
+				break
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if !p.isOp(")") {
+			return nil, p.unexpected()
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		body, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		item.Body = body
+		n.Items = append(n.Items, item)
+
+		// The last item may leave out its terminator
+		if p.isWord("esac") {
+			break
+		}
+		if !p.isOp(";;") && !p.isOp(";&") && !p.isOp(";;&") {
+			return nil, p.unexpected()
+		}
+		n.Items[len(n.Items)-1].Term = p.tok.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// parseCond parses a [[ ]] command from "[[" up to its closing "]]". Inside
+// it "&&", "||", "!" and parentheses combine tests, and newlines are
+// ignored.
+func (p *parser) parseCond() (*CondCommand, error) {
+	if err := p.advanceCond(); err != nil {
+		return nil, err
+	}
+	expr, err := p.parseCondOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isWord("]]") {
+		return nil, p.condError()
+	}
+	return &CondCommand{Expr: expr}, nil
+}
+
+func (p *parser) advanceCond() error {
+	if err := p.advance(); err != nil {
+		return err
+	}
+	return p.skipNewlines()
+}
+
+func (p *parser) condError() error {
+	if p.tok.kind == tokEOF {
+		return errIncomplete
+	}
+	text := p.tok.text
+	if p.tok.kind == tokNewline {
+		text = "newline"
+	}
+	return fmt.Errorf("syntax error in conditional expression: unexpected token `%s'", text)
+}
+
+// condWord returns the current token if it is an operand of a [[ ]] test.
+// A number before '<' or '>' is lexed as a file descriptor, but here it is
+// an operand like any other.
+func (p *parser) condWord() (string, bool) {
+	if p.tok.kind == tokWord && p.tok.text != "]]" || p.tok.kind == tokIONumber {
+		return p.tok.text, true
+	}
+	return "", false
+}
+
+func (p *parser) parseCondOr() (*CondExpr, error) {
+	x, err := p.parseCondAnd()
+	for err == nil && p.isOp("||") {
+		var y *CondExpr
+		if err = p.advanceCond(); err == nil {
+			y, err = p.parseCondAnd()
+		}
+		x = &CondExpr{Op: "||", X: x, Y: y}
+	}
+	return x, err
+}
+
+func (p *parser) parseCondAnd() (*CondExpr, error) {
+	x, err := p.parseCondNot()
+	for err == nil && p.isOp("&&") {
+		var y *CondExpr
+		if err = p.advanceCond(); err == nil {
+			y, err = p.parseCondNot()
+		}
+		x = &CondExpr{Op: "&&", X: x, Y: y}
+	}
+	return x, err
+}
+
+func (p *parser) parseCondNot() (*CondExpr, error) {
+	switch {
+	case p.isWord("!"):
+		if err := p.advanceCond(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseCondNot()
+		if err != nil {
+			return nil, err
+		}
+		return &CondExpr{Op: "!", X: x}, nil
+	case p.isOp("("):
+		if err := p.advanceCond(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseCondOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, p.condError()
+		}
+		return x, p.advanceCond()
+	}
+	return p.parseCondTest()
+}
+
+// parseCondTest parses a unary or binary test, or a single word
+func (p *parser) parseCondTest() (*CondExpr, error) {
+	first, ok := p.condWord()
+	if !ok {
+		return nil, p.condError()
+	}
+	if err := p.advanceCond(); err != nil {
+		return nil, err
+	}
+	if arg, ok := p.condWord(); ok && unaryTests[first] {
+		return &CondExpr{Op: first, Args: []string{arg}}, p.advanceCond()
+	}
+
+	op := p.tok.text
+	switch {
+	case p.tok.kind == tokWord && binaryTests[op], p.isOp("<"), p.isOp(">"):
+	default:
+		return &CondExpr{Args: []string{first}}, nil
+	}
+	var err error
+	if op == "=~" {
+		p.tok, err = p.lex.regexWord()
+	} else {
+		err = p.advance()
+	}
+	if err != nil {
+		return nil, err
+	}
+	second, ok := p.condWord()
+	if !ok || second == "" {
+		return nil, p.condError()
+	}
+	return &CondExpr{Op: op, Args: []string{first, second}}, p.advanceCond()
+}
+
+// parseRedirect consumes a redirection, with its optional file descriptor
+// number and its target word, and appends it to cmd.Redirects
+func (p *parser) parseRedirect(cmd *Command) error {
+	fd := -1
+	if p.tok.kind == tokIONumber {
+		n, err := strconv.Atoi(p.tok.text)
+		if err != nil {
+			return fmt.Errorf("%s: bad file descriptor", p.tok.text)
+		}
+		fd = n
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if !p.isRedirect() || p.isOp("&>") || p.isOp("&>>") {
+			return p.unexpected()
+		}
+	}
+	op := p.tok.text
+	if fd < 0 {
+		fd = redirectOps[op]
+	}
+
+	if err := p.advance(); err != nil {
+		return err
+	}
+	if p.tok.kind == tokEOF {
+		return fmt.Errorf("syntax error near unexpected token `newline'")
+	}
+	if p.tok.kind != tokWord {
+		return p.unexpected()
+	}
+
+	redirect := Redirect{Fd: fd, Op: op, Target: p.tok.text}
+	if op == "<<" || op == "<<-" {
+		redirect.Here = p.lex.hereDoc
+	}
+	cmd.Redirects = append(cmd.Redirects, redirect)
+	return p.advance()
+}
@@ -0,0 +1,595 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse_Lists(t *testing.T) {
+	tests := map[string]struct {
+		input         string
+		expectedItems int
+		expectedOps   [][]string
+	}{
+		"happy path - semicolon": {
+			input:         "echo a; echo b",
+			expectedItems: 2,
+			expectedOps:   [][]string{nil, nil},
+		},
+		"happy path - and or": {
+			input:         "make && ./run || echo failed",
+			expectedItems: 1,
+			expectedOps:   [][]string{{"&&", "||"}},
+		},
+		"happy path - newlines separate commands": {
+			input:         "echo a\n\necho b\n",
+			expectedItems: 2,
+			expectedOps:   [][]string{nil, nil},
+		},
+		"happy path - newline after operator": {
+			input:         "true &&\nfalse",
+			expectedItems: 1,
+			expectedOps:   [][]string{{"&&"}},
+		},
+		"edge case - trailing semicolon": {
+			input:         "echo a;",
+			expectedItems: 1,
+			expectedOps:   [][]string{nil},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			list, err := parse(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(list.Items) != tc.expectedItems {
+				t.Fatalf("expected %d items, got %d", tc.expectedItems, len(list.Items))
+			}
+			for i, item := range list.Items {
+				if len(item.Ops) != len(tc.expectedOps[i]) {
+					t.Errorf("item[%d]: expected ops %v, got %v", i, tc.expectedOps[i], item.Ops)
+					continue
+				}
+				for j, op := range item.Ops {
+					if op != tc.expectedOps[i][j] {
+						t.Errorf("item[%d]: expected ops %v, got %v", i, tc.expectedOps[i], item.Ops)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := map[string]struct {
+		input      string
+		incomplete bool
+	}{
+		"sad path - leading pipe":                     {input: "| cat"},
+		"sad path - double semicolon":                 {input: "echo a;;"},
+		"sad path - missing redirect target":          {input: "echo a >"},
+		"sad path - dangling and":                     {input: "echo a &&", incomplete: true},
+		"sad path - dangling pipe":                    {input: "echo a |", incomplete: true},
+		"sad path - empty subshell":                   {input: "( )"},
+		"sad path - stray closing brace":              {input: "echo a; }"},
+		"sad path - word after subshell":              {input: "(echo a) b"},
+		"sad path - unclosed subshell":                {input: "(echo a", incomplete: true},
+		"sad path - unclosed group":                   {input: "{ echo a; ", incomplete: true},
+		"sad path - group needs separator":            {input: "{ echo a }", incomplete: true},
+		"sad path - if without then":                  {input: "if true; fi"},
+		"sad path - empty then part":                  {input: "if true; then fi"},
+		"sad path - stray fi":                         {input: "echo a; fi"},
+		"sad path - unclosed if":                      {input: "if true; then echo a", incomplete: true},
+		"sad path - elif after else":                  {input: "if a; then b; else c; elif d; then e; fi"},
+		"sad path - for without do":                   {input: "for x in a; echo $x; done"},
+		"sad path - for needs a name":                 {input: "for 1x in a; do echo; done"},
+		"sad path - for words need a separator":       {input: "for x in a do echo; done"},
+		"sad path - arithmetic for needs three parts": {input: "for ((i=0; i<3)); do echo; done"},
+		"sad path - stray done":                       {input: "echo a; done"},
+		"sad path - case without in":                  {input: "case x; a) echo;; esac"},
+		"sad path - case pattern without paren":       {input: "case x in a echo;; esac"},
+		"sad path - case item without terminator":     {input: "case x in a) echo a b) echo b;; esac"},
+		"sad path - unclosed case":                    {input: "case x in a) echo a;;", incomplete: true},
+		"sad path - stray esac":                       {input: "echo a; esac"},
+		"sad path - function body not compound":       {input: "f() echo hi"},
+		"sad path - function with arguments":          {input: "f a() { echo; }"},
+		"sad path - function name quoted":             {input: "'f'() { echo; }"},
+		"sad path - unclosed function":                {input: "f() {\necho", incomplete: true},
+		"sad path - empty conditional":                {input: "[[ ]]"},
+		"sad path - conditional missing operand":      {input: "[[ a == ]]"},
+		"sad path - conditional extra word":           {input: "[[ a b ]]"},
+		"sad path - conditional unclosed group":       {input: "[[ ( a ]]"},
+		"sad path - unclosed conditional":             {input: "[[ -n a", incomplete: true},
+		"sad path - unclosed while":                   {input: "while true; do echo a", incomplete: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parse(tc.input)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if errors.Is(err, errIncomplete) != tc.incomplete {
+				t.Errorf("expected incomplete=%v, got %v", tc.incomplete, err)
+			}
+		})
+	}
+}
+
+func TestParse_Assignments(t *testing.T) {
+	tests := map[string]struct {
+		input           string
+		expectedAssigns []string
+		expectedWords   []string
+	}{
+		"happy path - assignment only": {
+			input:           "FOO=bar",
+			expectedAssigns: []string{"FOO=bar"},
+		},
+		"happy path - prefix assignments": {
+			input:           "A=1 B=2 env",
+			expectedAssigns: []string{"A=1", "B=2"},
+			expectedWords:   []string{"env"},
+		},
+		"happy path - assignment after name is an argument": {
+			input:         "echo A=1",
+			expectedWords: []string{"echo", "A=1"},
+		},
+		"happy path - quoted name is not an assignment": {
+			input:         `"A"=1`,
+			expectedWords: []string{`"A"=1`},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			list, err := parse(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			cmd := list.Items[0].Pipelines[0].Cmd
+			if strings.Join(cmd.Assigns, " ") != strings.Join(tc.expectedAssigns, " ") {
+				t.Errorf("expected assigns %v, got %v", tc.expectedAssigns, cmd.Assigns)
+			}
+			if strings.Join(cmd.Words, " ") != strings.Join(tc.expectedWords, " ") {
+				t.Errorf("expected words %v, got %v", tc.expectedWords, cmd.Words)
+			}
+		})
+	}
+}
+
+func TestParse_ArithCommand(t *testing.T) {
+	list, err := parse("(( x += 1 )) || echo zero")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first := list.Items[0].Pipelines[0].Cmd
+	arith, ok := first.Compound.(*ArithCommand)
+	if !ok {
+		t.Fatalf("expected an arithmetic command, got %#v", first)
+	}
+	if arith.Expr != " x += 1 " {
+		t.Errorf("expected expression %q, got %q", " x += 1 ", arith.Expr)
+	}
+	if ops := list.Items[0].Ops; len(ops) != 1 || ops[0] != "||" {
+		t.Errorf("expected a || list, got %v", ops)
+	}
+}
+
+func TestParse_HereDoc(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected []HereDoc
+	}{
+		"happy path - body": {
+			input:    "cat <<EOF\nline $x\nEOF",
+			expected: []HereDoc{{Delim: "EOF", Body: "line $x\n"}},
+		},
+		"happy path - quoted delimiter": {
+			input:    "cat <<'EOF'\nline\nEOF\n",
+			expected: []HereDoc{{Delim: "EOF", Quoted: true, Body: "line\n"}},
+		},
+		"happy path - tabs stripped": {
+			input:    "cat <<-EOF\n\t\tline\n\tEOF\n",
+			expected: []HereDoc{{Delim: "EOF", StripTabs: true, Body: "line\n"}},
+		},
+		"happy path - two on one line": {
+			input:    "cat <<A 3<<B\na\nA\nb\nB\n",
+			expected: []HereDoc{{Delim: "A", Body: "a\n"}, {Delim: "B", Body: "b\n"}},
+		},
+		"edge case - empty body": {
+			input:    "cat <<EOF\nEOF",
+			expected: []HereDoc{{Delim: "EOF"}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			list, err := parse(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			redirects := list.Items[0].Pipelines[0].Cmd.Redirects
+			if len(redirects) != len(tc.expected) {
+				t.Fatalf("expected %d redirections, got %d", len(tc.expected), len(redirects))
+			}
+			for i, r := range redirects {
+				if r.Here == nil || *r.Here != tc.expected[i] {
+					t.Errorf("expected here-document %+v, got %+v", tc.expected[i], r.Here)
+				}
+			}
+		})
+	}
+}
+
+func TestParse_Compound(t *testing.T) {
+	tests := map[string]struct {
+		input             string
+		expectedType      string
+		expectedItems     int
+		expectedRedirects int
+	}{
+		"happy path - subshell": {
+			input:         "(cd /tmp; ls)",
+			expectedType:  "*main.Subshell",
+			expectedItems: 2,
+		},
+		"happy path - group": {
+			input:         "{ echo a; echo b; }",
+			expectedType:  "*main.Group",
+			expectedItems: 2,
+		},
+		"happy path - group with redirections": {
+			input:             "{ echo a\necho b\n} > log 2>&1",
+			expectedType:      "*main.Group",
+			expectedItems:     2,
+			expectedRedirects: 2,
+		},
+		"happy path - nested": {
+			input:         "( { echo a; } )",
+			expectedType:  "*main.Subshell",
+			expectedItems: 1,
+		},
+		"happy path - closing brace as an argument": {
+			input:         "{ echo }; }",
+			expectedType:  "*main.Group",
+			expectedItems: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			list, err := parse(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			cmd := list.Items[0].Pipelines[0].Cmd
+			var body *List
+			switch n := cmd.Compound.(type) {
+			case *Subshell:
+				body = n.Body
+			case *Group:
+				body = n.Body
+			}
+			if typ := fmt.Sprintf("%T", cmd.Compound); typ != tc.expectedType {
+				t.Fatalf("expected %s, got %s", tc.expectedType, typ)
+			}
+			if len(body.Items) != tc.expectedItems {
+				t.Errorf("expected %d items, got %d", tc.expectedItems, len(body.Items))
+			}
+			if len(cmd.Redirects) != tc.expectedRedirects {
+				t.Errorf("expected %d redirections, got %d", tc.expectedRedirects, len(cmd.Redirects))
+			}
+		})
+	}
+}
+
+func TestParse_If(t *testing.T) {
+	tests := map[string]struct {
+		input             string
+		expectedClauses   int
+		expectedElse      bool
+		expectedRedirects int
+	}{
+		"happy path - if then": {
+			input:           "if true; then echo a; fi",
+			expectedClauses: 1,
+		},
+		"happy path - else": {
+			input:           "if false\nthen\n  echo a\nelse\n  echo b\nfi",
+			expectedClauses: 1,
+			expectedElse:    true,
+		},
+		"happy path - elif chain": {
+			input:           "if a; then b; elif c; then d; elif e; then f; else g; fi",
+			expectedClauses: 3,
+			expectedElse:    true,
+		},
+		"happy path - redirections after fi": {
+			input:             "if true; then echo a; fi > out 2>&1",
+			expectedClauses:   1,
+			expectedRedirects: 2,
+		},
+		"happy path - reserved words as arguments": {
+			input:           "if echo then fi; then echo else; fi",
+			expectedClauses: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			list, err := parse(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			cmd := list.Items[0].Pipelines[0].Cmd
+			n, ok := cmd.Compound.(*If)
+			if !ok {
+				t.Fatalf("expected an if command, got %T", cmd.Compound)
+			}
+			if len(n.Clauses) != tc.expectedClauses {
+				t.Errorf("expected %d clauses, got %d", tc.expectedClauses, len(n.Clauses))
+			}
+			if (n.Else != nil) != tc.expectedElse {
+				t.Errorf("expected else=%v, got %v", tc.expectedElse, n.Else != nil)
+			}
+			if len(cmd.Redirects) != tc.expectedRedirects {
+				t.Errorf("expected %d redirections, got %d", tc.expectedRedirects, len(cmd.Redirects))
+			}
+		})
+	}
+}
+
+func TestParse_Loops(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected Node
+	}{
+		"happy path - for in": {
+			input:    "for x in a 'b c' *; do echo $x; done",
+			expected: &For{Var: "x", Words: []string{"a", "'b c'", "*"}},
+		},
+		"happy path - for over positional parameters": {
+			input:    "for x\ndo\n  echo $x\ndone",
+			expected: &For{Var: "x", Words: []string{`"$@"`}},
+		},
+		"happy path - for over no words": {
+			input:    "for x in; do echo $x; done",
+			expected: &For{Var: "x", Words: []string{}},
+		},
+		"happy path - arithmetic for": {
+			input:    "for ((i=0; i<3; i++)) do echo $i; done",
+			expected: &ArithFor{Init: "i=0", Cond: " i<3", Step: " i++"},
+		},
+		"happy path - while": {
+			input:    "while read line; do echo $line; done",
+			expected: &While{},
+		},
+		"happy path - until": {
+			input:    "until false\ndo\n  break\ndone",
+			expected: &While{Until: true},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			list, err := parse(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var body *List
+			switch n := list.Items[0].Pipelines[0].Cmd.Compound.(type) {
+			case *For:
+				body, n.Body = n.Body, nil
+			case *ArithFor:
+				body, n.Body = n.Body, nil
+			case *While:
+				if n.Cond == nil {
+					t.Fatal("expected a condition")
+				}
+				body, n.Body, n.Cond = n.Body, nil, nil
+			}
+			compound := list.Items[0].Pipelines[0].Cmd.Compound
+			if !reflect.DeepEqual(compound, tc.expected) {
+				t.Errorf("expected %#v, got %#v", tc.expected, compound)
+			}
+			if body == nil || len(body.Items) != 1 {
+				t.Errorf("expected a body of one command, got %v", body)
+			}
+		})
+	}
+}
+
+func TestParse_Case(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected *Case
+	}{
+		"happy path - items": {
+			input: "case $x in a|b) echo ab;; (c) echo c;& *) echo any;;& esac",
+			expected: &Case{Word: "$x", Items: []CaseItem{
+				{Patterns: []string{"a", "b"}, Term: ";;"},
+				{Patterns: []string{"c"}, Term: ";&"},
+				{Patterns: []string{"*"}, Term: ";;&"},
+			}},
+		},
+		"happy path - newlines and no final terminator": {
+			input: "case x\nin\n  x)\n    echo x\n    ;;\n  '*')\n    echo star\nesac",
+			expected: &Case{Word: "x", Items: []CaseItem{
+				{Patterns: []string{"x"}, Term: ";;"},
+				{Patterns: []string{"'*'"}, Term: ";;"},
+			}},
+		},
+		"happy path - empty body": {
+			input:    "case x in x) ;; esac",
+			expected: &Case{Word: "x", Items: []CaseItem{{Patterns: []string{"x"}, Term: ";;"}}},
+		},
+		"happy path - no items": {
+			input:    "case x in esac",
+			expected: &Case{Word: "x"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			list, err := parse(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			n, ok := list.Items[0].Pipelines[0].Cmd.Compound.(*Case)
+			if !ok {
+				t.Fatalf("expected a case command, got %T", list.Items[0].Pipelines[0].Cmd.Compound)
+			}
+			for i := range n.Items {
+				n.Items[i].Body = nil
+			}
+			if !reflect.DeepEqual(n, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, n)
+			}
+		})
+	}
+}
+
+func TestParse_Function(t *testing.T) {
+	tests := map[string]struct {
+		input             string
+		expectedName      string
+		expectedSource    string
+		expectedBody      string
+		expectedRedirects int
+	}{
+		"happy path - parentheses": {
+			input:          "greet() { echo hi $1; }",
+			expectedName:   "greet",
+			expectedSource: "{ echo hi $1; }",
+			expectedBody:   "*main.Group",
+		},
+		"happy path - keyword": {
+			input:          "function greet { echo hi; }",
+			expectedName:   "greet",
+			expectedSource: "{ echo hi; }",
+			expectedBody:   "*main.Group",
+		},
+		"happy path - keyword and parentheses": {
+			input:          "function greet () {\n  echo hi\n}",
+			expectedName:   "greet",
+			expectedSource: "{\n  echo hi\n}",
+			expectedBody:   "*main.Group",
+		},
+		"happy path - body on the next line": {
+			input:          "f ()\n(cd /tmp)",
+			expectedName:   "f",
+			expectedSource: "(cd /tmp)",
+			expectedBody:   "*main.Subshell",
+		},
+		"happy path - any compound body": {
+			input:          "is_big() if (( $1 > 9 )); then echo big; fi",
+			expectedName:   "is_big",
+			expectedSource: "if (( $1 > 9 )); then echo big; fi",
+			expectedBody:   "*main.If",
+		},
+		"happy path - redirections belong to the body": {
+			input:             "log() { echo $1; } >> log.txt; log a",
+			expectedName:      "log",
+			expectedSource:    "{ echo $1; } >> log.txt",
+			expectedBody:      "*main.Group",
+			expectedRedirects: 1,
+		},
+		"happy path - name with a dash": {
+			input:          "my-func() { echo; }",
+			expectedName:   "my-func",
+			expectedSource: "{ echo; }",
+			expectedBody:   "*main.Group",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			list, err := parse(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			def, ok := list.Items[0].Pipelines[0].Cmd.Compound.(*FuncDef)
+			if !ok {
+				t.Fatalf("expected a function definition, got %T", list.Items[0].Pipelines[0].Cmd.Compound)
+			}
+			if def.Name != tc.expectedName {
+				t.Errorf("expected name %q, got %q", tc.expectedName, def.Name)
+			}
+			if def.Source != tc.expectedSource {
+				t.Errorf("expected source %q, got %q", tc.expectedSource, def.Source)
+			}
+			if typ := fmt.Sprintf("%T", def.Body.Compound); typ != tc.expectedBody {
+				t.Errorf("expected body %s, got %s", tc.expectedBody, typ)
+			}
+			if len(def.Body.Redirects) != tc.expectedRedirects {
+				t.Errorf("expected %d redirections, got %d", tc.expectedRedirects, len(def.Body.Redirects))
+			}
+		})
+	}
+}
+
+func TestParse_Cond(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected *CondExpr
+	}{
+		"happy path - word": {
+			input:    "[[ $x ]]",
+			expected: &CondExpr{Args: []string{"$x"}},
+		},
+		"happy path - unary": {
+			input:    "[[ -f $file ]]",
+			expected: &CondExpr{Op: "-f", Args: []string{"$file"}},
+		},
+		"happy path - binary": {
+			input:    `[[ $a == "b"* ]]`,
+			expected: &CondExpr{Op: "==", Args: []string{"$a", `"b"*`}},
+		},
+		"happy path - string order operators": {
+			input:    "[[ 2 < 10 ]]",
+			expected: &CondExpr{Op: "<", Args: []string{"2", "10"}},
+		},
+		"happy path - regex keeps parentheses and bars": {
+			input:    "[[ $x =~ ^(a|b)+[0-9]$ ]]",
+			expected: &CondExpr{Op: "=~", Args: []string{"$x", "^(a|b)+[0-9]$"}},
+		},
+		"happy path - and binds tighter than or": {
+			input: "[[ a || b && ! c ]]",
+			expected: &CondExpr{Op: "||", X: &CondExpr{Args: []string{"a"}}, Y: &CondExpr{
+				Op: "&&", X: &CondExpr{Args: []string{"b"}}, Y: &CondExpr{Op: "!", X: &CondExpr{Args: []string{"c"}}},
+			}},
+		},
+		"happy path - grouping": {
+			input: "[[ ( a || b ) && c ]]",
+			expected: &CondExpr{Op: "&&", X: &CondExpr{
+				Op: "||", X: &CondExpr{Args: []string{"a"}}, Y: &CondExpr{Args: []string{"b"}},
+			}, Y: &CondExpr{Args: []string{"c"}}},
+		},
+		"happy path - operator as a word": {
+			input:    "[[ -n ]]",
+			expected: &CondExpr{Args: []string{"-n"}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			list, err := parse(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			n, ok := list.Items[0].Pipelines[0].Cmd.Compound.(*CondCommand)
+			if !ok {
+				t.Fatalf("expected a conditional command, got %T", list.Items[0].Pipelines[0].Cmd.Compound)
+			}
+			if !reflect.DeepEqual(n.Expr, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, n.Expr)
+			}
+		})
+	}
+}
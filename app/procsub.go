@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// procSub is a running process substitution. file is the shell's end of
+// the pipe to it; done is closed when the commands of a ">(...)" finish.
+type procSub struct {
+	file *os.File
+	done chan struct{}
+}
+
+// processSubstitution starts the commands of the "<(...)" or ">(...)" at
+// the start of word in a subshell, writing to or reading from a pipe. It
+// returns the /dev/fd path of the shell's end of the pipe and the length of
+// the substitution.
+func (s *Shell) processSubstitution(word string) (string, int, error) {
+	l := lexer{src: word, pos: 1}
+	if err := l.skipSubstitution(); err != nil {
+		return word[:1], 1, nil
+	}
+	list, err := parse(word[2 : l.pos-1])
+	if err != nil {
+		return "", 0, err
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", 0, err
+	}
+	// The list inherits the descriptors of the command it is part of
+	ours, theirs := r, w
+	fds := s.inheritedFds()
+	var done chan struct{}
+	if word[0] == '>' {
+		ours, theirs = w, r
+		fds[0] = r
+		done = make(chan struct{})
+	} else {
+		fds[1] = w
+	}
+	// A stdin that is not a file, such as a builtin's buffer, would be read
+	// by both the list and the command at once, so the list gets /dev/null
+	var null *os.File
+	if _, ok := fds[0].(*os.File); !ok {
+		if null, err = os.Open(os.DevNull); err != nil {
+			r.Close()
+			w.Close()
+			return "", 0, err
+		}
+		fds[0] = null
+	}
+
+	sub := s.subshell()
+	go func() {
+		sub.runList(list, fds)
+		theirs.Close()
+		if null != nil {
+			null.Close()
+		}
+		if done != nil {
+			close(done)
+		}
+	}()
+
+	s.procSubs = append(s.procSubs, procSub{file: ours, done: done})
+	return fmt.Sprintf("/dev/fd/%d", ours.Fd()), l.pos, nil
+}
+
+// addProcSubs adds the shell's ends of the running process substitutions
+// to fds under their own numbers, so that their /dev/fd paths also name
+// them in an external command
+func (s *Shell) addProcSubs(fds fdTable) {
+	for _, p := range s.procSubs {
+		fds[int(p.file.Fd())] = p.file
+	}
+}
+
+// closeProcSubs closes the process substitutions started since the first
+// mark of them, once the command using them has finished. Closing a
+// ">(...)" pipe ends its input, and its commands are waited for so that
+// their output comes before that of the next command.
+func (s *Shell) closeProcSubs(mark int) {
+	for _, p := range s.procSubs[mark:] {
+		p.file.Close()
+		if p.done != nil {
+			<-p.done
+		}
+	}
+	s.procSubs = s.procSubs[:mark]
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShell_processSubstitution(t *testing.T) {
+	tests := map[string]struct {
+		input        string
+		stdin        string
+		expectedOut  string
+		expectedFile string
+	}{
+		"happy path - input to an external command": {
+			input:       "cat <(echo a) <(echo b)",
+			expectedOut: "a\nb\n",
+		},
+		"happy path - input redirection": {
+			input:       "wc -l < <(printf '1\\n2\\n')",
+			expectedOut: "2\n",
+		},
+		"happy path - output from an external command": {
+			input:        "echo piped | tee >(tr a-z A-Z > f) > /dev/null",
+			expectedFile: "PIPED\n",
+		},
+		"happy path - output redirection": {
+			input:        "echo hi > >(cat > f)",
+			expectedFile: "hi\n",
+		},
+		"happy path - path names a descriptor": {
+			input:       "echo <(true) | grep -c '^/dev/fd/[0-9]*$'",
+			expectedOut: "1\n",
+		},
+		"happy path - reader stops early": {
+			input:       "head -1 <(yes)",
+			expectedOut: "y\n",
+		},
+		"happy path - inherits stderr": {
+			input:       "{ read line < <(echo oops >&2; echo in); echo $line; } 2>&1",
+			expectedOut: "oops\nin\n",
+		},
+		"happy path - output inherits stdout": {
+			input:        "{ echo hi > >(cat); } > f",
+			expectedFile: "hi\n",
+		},
+		"edge case - stdin stays with the command": {
+			input:       "cat - <(cat)",
+			stdin:       "in\n",
+			expectedOut: "in\n",
+		},
+		"edge case - quoted is literal": {
+			input:       `echo "<(x)"`,
+			expectedOut: "<(x)\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			oldDir, _ := os.Getwd()
+			os.Chdir(dir)
+			defer os.Chdir(oldDir)
+
+			shell := NewShell()
+			cmd, err := shell.parseInput(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+
+			var stdout bytes.Buffer
+			if err := shell.runCommand(cmd, strings.NewReader(tc.stdin), &stdout); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if stdout.String() != tc.expectedOut {
+				t.Errorf("expected output %q, got %q", tc.expectedOut, stdout.String())
+			}
+			if data, _ := os.ReadFile(filepath.Join(dir, "f")); string(data) != tc.expectedFile {
+				t.Errorf("expected file %q, got %q", tc.expectedFile, data)
+			}
+			if len(shell.procSubs) != 0 {
+				t.Errorf("expected process substitutions to be closed, got %d", len(shell.procSubs))
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// fdTable holds the file descriptors a command runs with. The standard
+// descriptors may be any io.Reader or io.Writer, such as a pipe or a buffer
+// capturing output; those a redirection opens are *os.File.
+type fdTable map[int]any
+
+// newFdTable returns the descriptors of a command before its redirections
+func newFdTable(stdin io.Reader, stdout, stderr io.Writer) fdTable {
+	return fdTable{0: stdin, 1: stdout, 2: stderr}
+}
+
+// closedFd stands in for a closed descriptor, which fails every read and
+// write with EBADF
+type closedFd struct{}
+
+func (closedFd) Read([]byte) (int, error)  { return 0, syscall.EBADF }
+func (closedFd) Write([]byte) (int, error) { return 0, syscall.EBADF }
+
+// reader returns descriptor fd for reading
+func (t fdTable) reader(fd int) io.Reader {
+	if r, ok := t[fd].(io.Reader); ok {
+		return r
+	}
+	return closedFd{}
+}
+
+// writer returns descriptor fd for writing
+func (t fdTable) writer(fd int) io.Writer {
+	if w, ok := t[fd].(io.Writer); ok {
+		return w
+	}
+	return closedFd{}
+}
+
+// withFds makes fds the descriptors that substitutions inherit and returns
+// a function that restores the previous ones
+func (s *Shell) withFds(fds fdTable) func() {
+	saved := s.fds
+	s.fds = fds
+	return func() { s.fds = saved }
+}
+
+// inheritedFds returns a copy of the descriptors a substitution starts
+// with: those of the command being run, or the shell's own outside one
+func (s *Shell) inheritedFds() fdTable {
+	if s.fds == nil {
+		return newFdTable(os.Stdin, os.Stdout, os.Stderr)
+	}
+	return maps.Clone(s.fds)
+}
+
+// extraFiles returns the descriptors from 3 up as the ExtraFiles of an
+// exec.Cmd. Gaps, and streams that are not files, become nil entries, which
+// leave the child's descriptor closed.
+func (t fdTable) extraFiles() []*os.File {
+	var files []*os.File
+	for fd, f := range t {
+		if fd < 3 {
+			continue
+		}
+		for len(files) <= fd-3 {
+			files = append(files, nil)
+		}
+		files[fd-3], _ = f.(*os.File)
+	}
+	return files
+}
+
+// ioContext is what a builtin reads from and writes to: the standard
+// streams of its command after the redirections, and in fds every
+// descriptor, for builtins that start other programs
+type ioContext struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	fds    fdTable
+}
+
+func newIOContext(fds fdTable) *ioContext {
+	stdout := &errorWriter{w: fds.writer(1)}
+	return &ioContext{stdin: fds.reader(0), stdout: stdout, stderr: fds.writer(2), fds: fds}
+}
+
+// writeError returns the first error a builtin got writing to its stdout
+func (c *ioContext) writeError() error {
+	if w, ok := c.stdout.(*errorWriter); ok {
+		return w.err
+	}
+	return nil
+}
+
+// errorWriter remembers the first error from writing to w, so that a
+// builtin can fail with a write error without checking every write
+type errorWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errorWriter) Write(p []byte) (int, error) {
+	n, err := e.w.Write(p)
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+	return n, err
+}
+
+// redirectFlags maps the operators that open a file to the open flags
+var redirectFlags = map[string]int{
+	"<":   os.O_RDONLY,
+	"<>":  os.O_RDWR | os.O_CREATE,
+	">":   os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+	">|":  os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+	">>":  os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+	"&>":  os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+	"&>>": os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+}
+
+// redirect applies redirects to fds from left to right, so "> f 2>&1" sends
+// both outputs to f while "2>&1 > f" sends only stdout there. It returns a
+// function that closes the files it opened; on error they are already closed.
+func (s *Shell) redirect(fds fdTable, redirects []Redirect) (func(), error) {
+	var opened []*os.File
+	closeFiles := func() {
+		for _, f := range opened {
+			f.Close()
+		}
+	}
+
+	for _, r := range redirects {
+		if r.Op == "<<" || r.Op == "<<-" || r.Op == "<<<" {
+			f, err := s.hereDocument(r)
+			if err != nil {
+				closeFiles()
+				return nil, err
+			}
+			opened = append(opened, f)
+			fds[r.Fd] = f
+			continue
+		}
+
+		target, err := s.redirectTarget(r.Target)
+		if err != nil {
+			closeFiles()
+			return nil, err
+		}
+
+		op := r.Op
+		if op == ">&" || op == "<&" {
+			n, err := strconv.Atoi(target)
+			switch {
+			case target == "-":
+				delete(fds, r.Fd)
+				continue
+			case err == nil:
+				f, ok := fds[n]
+				if !ok {
+					closeFiles()
+					return nil, fmt.Errorf("%d: Bad file descriptor", n)
+				}
+				fds[r.Fd] = f
+				continue
+			case op == ">&" && r.Fd == 1:
+				// ">&file" is the old spelling of "&>file"
+				op = "&>"
+			default:
+				closeFiles()
+				return nil, fmt.Errorf("%s: ambiguous redirect", r.Target)
+			}
+		}
+
+		flags := redirectFlags[op]
+		if s.options["noclobber"] && (op == ">" || op == "&>") {
+			// Only ">|" may truncate an existing regular file
+			info, err := os.Stat(s.resolvePath(target))
+			if err == nil && info.Mode().IsRegular() {
+				closeFiles()
+				return nil, fmt.Errorf("%s: cannot overwrite existing file", target)
+			}
+			if err != nil {
+				flags |= os.O_EXCL
+			}
+		}
+
+		f, err := os.OpenFile(s.resolvePath(target), flags, FilePermission)
+		if err != nil {
+			closeFiles()
+			return nil, fmt.Errorf("%s: %s", target, strerror(err))
+		}
+		opened = append(opened, f)
+		fds[r.Fd] = f
+		if op == "&>" || op == "&>>" {
+			fds[2] = f
+		}
+	}
+	return closeFiles, nil
+}
+
+// hereDocument returns the read end of a pipe that delivers the body of a
+// here-document, or the word of a "<<<" here-string followed by a newline
+func (s *Shell) hereDocument(r Redirect) (*os.File, error) {
+	var body string
+	var err error
+	switch {
+	case r.Here == nil:
+		body, err = s.expandWord(r.Target)
+		body += "\n"
+	case r.Here.Quoted:
+		body = r.Here.Body
+	default:
+		body, err = s.expandHereDoc(r.Here.Body)
+	}
+	if err != nil {
+		return nil, expandError{err}
+	}
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	// A command that exits without reading it all closes the read end,
+	// which ends the write with an error
+	go func() {
+		io.WriteString(pw, body)
+		pw.Close()
+	}()
+	return pr, nil
+}
+
+// redirectTarget expands the target word of a redirection, which has to
+// produce exactly one field
+func (s *Shell) redirectTarget(word string) (string, error) {
+	fields, err := s.expandWords([]string{word})
+	if err != nil {
+		return "", expandError{err}
+	}
+	if len(fields) != 1 {
+		return "", fmt.Errorf("%s: ambiguous redirect", word)
+	}
+	return fields[0], nil
+}
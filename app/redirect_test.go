@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShell_redirect(t *testing.T) {
+	tests := map[string]struct {
+		input          string
+		expectedOut    string
+		expectedFile   string
+		expectedStatus int
+	}{
+		"happy path - output to file": {
+			input:        "echo hi > f",
+			expectedFile: "hi\n",
+		},
+		"happy path - append": {
+			input:        "echo a > f; echo b >> f",
+			expectedFile: "a\nb\n",
+		},
+		"happy path - input from file": {
+			input:        "echo hi > f; cat < f",
+			expectedOut:  "hi\n",
+			expectedFile: "hi\n",
+		},
+		"happy path - stderr follows stdout to file": {
+			input:        "sh -c 'echo err >&2' > f 2>&1",
+			expectedFile: "err\n",
+		},
+		"happy path - stderr duplicated before stdout moves": {
+			input:       "sh -c 'echo err >&2' 2>&1 > f",
+			expectedOut: "err\n",
+		},
+		"happy path - both outputs": {
+			input:        "sh -c 'echo out; echo err >&2' &> f",
+			expectedFile: "out\nerr\n",
+		},
+		"happy path - both outputs appended": {
+			input:        "echo a > f; sh -c 'echo err >&2' &>> f",
+			expectedFile: "a\nerr\n",
+		},
+		"happy path - builtin output to stderr": {
+			input:        "echo hi 2> f >&2",
+			expectedFile: "hi\n",
+		},
+		"happy path - builtin output to file": {
+			input:        "type type > f",
+			expectedFile: "type is a shell builtin\n",
+		},
+		"happy path - builtin errors to file": {
+			input:          "cd /nonexistent_directory_xyz 2> f",
+			expectedFile:   "cd: /nonexistent_directory_xyz: No such file or directory\n",
+			expectedStatus: 1,
+		},
+		"happy path - builtin errors through a pipe": {
+			input:       "set -Z 2>&1 | tr a-z A-Z",
+			expectedOut: "SET: -Z: INVALID OPTION\n",
+		},
+		"happy path - builtin output appended": {
+			input:        "echo a > f; shopt -s nullglob; shopt -p nullglob >> f",
+			expectedFile: "a\nshopt -s nullglob\n",
+		},
+		"happy path - extra descriptor": {
+			input:        "sh -c 'echo three >&3' 3> f",
+			expectedFile: "three\n",
+		},
+		"happy path - read and write": {
+			input:        "echo hi > f; cat 0<> f",
+			expectedOut:  "hi\n",
+			expectedFile: "hi\n",
+		},
+		"sad path - builtin writing to closed output": {
+			input:          "echo hi >&-",
+			expectedStatus: 1,
+		},
+		"sad path - builtin reading closed input": {
+			input:          "read v <&-",
+			expectedStatus: 1,
+		},
+		"sad path - program writing to closed output": {
+			input:          "echo hi > f; cat f >&-",
+			expectedFile:   "hi\n",
+			expectedStatus: 1,
+		},
+		"sad path - program reading closed input": {
+			input:          "cat <&-",
+			expectedStatus: 1,
+		},
+		"happy path - redirection without a command creates the file": {
+			input: "> f",
+		},
+		"happy path - here-document": {
+			input:       "X=1; cat <<EOF\n$X \\$X 'q' $(echo sub)\nEOF",
+			expectedOut: "1 $X 'q' sub\n",
+		},
+		"happy path - quoted here-document": {
+			input:       "X=1; cat <<'EOF'\n$X\nEOF",
+			expectedOut: "$X\n",
+		},
+		"happy path - here-document with tabs stripped": {
+			input:       "cat <<-EOF\n\tindented\n\tEOF",
+			expectedOut: "indented\n",
+		},
+		"happy path - here-document on another descriptor": {
+			input:       "cat 3<<EOF <&3\nthree\nEOF",
+			expectedOut: "three\n",
+		},
+		"happy path - here-document into a pipeline": {
+			input:       "cat <<EOF | tr a-z A-Z\npiped\nEOF",
+			expectedOut: "PIPED\n",
+		},
+		"happy path - here-string": {
+			input:       `X="a  b"; cat <<< $X`,
+			expectedOut: "a  b\n",
+		},
+		"happy path - noclobber creates a new file": {
+			input:        "set -C; echo new > f",
+			expectedFile: "new\n",
+		},
+		"happy path - noclobber overridden": {
+			input:        "echo old > f; set -o noclobber; echo new >| f",
+			expectedFile: "new\n",
+		},
+		"happy path - noclobber allows appending": {
+			input:        "echo old > f; set -C; echo new >> f",
+			expectedFile: "old\nnew\n",
+		},
+		"happy path - noclobber allows devices": {
+			input: "set -C; echo new > /dev/null",
+		},
+		"sad path - noclobber refuses to truncate": {
+			input:          "echo old > f; set -C; echo new > f",
+			expectedFile:   "old\n",
+			expectedStatus: 1,
+		},
+		"sad path - noclobber refuses both outputs": {
+			input:          "echo old > f; set -C; echo new &> f",
+			expectedFile:   "old\n",
+			expectedStatus: 1,
+		},
+		"sad path - missing input file": {
+			input:          "cat < missing",
+			expectedStatus: 1,
+		},
+		"sad path - bad descriptor": {
+			input:          "echo hi >&7",
+			expectedStatus: 1,
+		},
+		"sad path - ambiguous redirect": {
+			input:          `F="a b"; echo hi > $F`,
+			expectedStatus: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			shell := NewShell()
+			shell.cwd = dir
+			cmd, err := shell.parseInput(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+
+			var stdout bytes.Buffer
+			oldStderr := os.Stderr
+			os.Stderr, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+			err = shell.runCommand(cmd, strings.NewReader(""), &stdout)
+			os.Stderr.Close()
+			os.Stderr = oldStderr
+
+			if status := exitCode(err); status != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, status)
+			}
+			if stdout.String() != tc.expectedOut {
+				t.Errorf("expected output %q, got %q", tc.expectedOut, stdout.String())
+			}
+			if data, _ := os.ReadFile(filepath.Join(dir, "f")); string(data) != tc.expectedFile {
+				t.Errorf("expected file %q, got %q", tc.expectedFile, data)
+			}
+		})
+	}
+}
+
+func TestShell_redirect_OpensBeforeRunning(t *testing.T) {
+	tests := map[string]struct {
+		input          string
+		file           string
+		expected       string
+		expectedStatus int
+	}{
+		"happy path - target exists when the command starts": {
+			input:    "ls > f",
+			file:     "f",
+			expected: "f\n",
+		},
+		"happy path - output reaches the file while the command runs": {
+			input:    "sh -c 'echo partial; cat f > seen' > f",
+			file:     "seen",
+			expected: "partial\n",
+		},
+		"happy path - stderr reaches the file while the command runs": {
+			input:    "sh -c 'echo partial >&2; cat f > seen' 2> f",
+			file:     "seen",
+			expected: "partial\n",
+		},
+		"sad path - open error keeps the command from running": {
+			input:          "touch ran > missing/f",
+			file:           "ran",
+			expectedStatus: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			shell := NewShell()
+			shell.cwd = dir
+			cmd, err := shell.parseInput(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+
+			oldStderr := os.Stderr
+			os.Stderr, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+			err = shell.runCommand(cmd, strings.NewReader(""), io.Discard)
+			os.Stderr.Close()
+			os.Stderr = oldStderr
+
+			if status := exitCode(err); status != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, status)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, tc.file))
+			if tc.expectedStatus != 0 {
+				if err == nil {
+					t.Errorf("expected %s not to exist", tc.file)
+				}
+				return
+			}
+			if string(data) != tc.expected {
+				t.Errorf("expected %s to hold %q, got %q", tc.file, tc.expected, data)
+			}
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 
@@ -15,9 +19,30 @@ type Shell struct {
 	allCommands          []string
 	history              []string
 	historyAppendedCount int
+	lastStatus           int
+	pipeStatus           []int
+	options              map[string]bool
+	vars                 map[string]*Variable
+	inSubshell           bool
+	substStatus          int
+	arg0                 string
+	params               []string
+	procSubs             []procSub
+	// fds holds the descriptors of the command being run, which command and
+	// process substitutions in its words inherit
+	fds       fdTable
+	cwd       string
+	loopDepth int
+	funcs     map[string]*FuncDef
+	// locals holds a scope for each function being run, mapping the names
+	// of its local variables to the variables they hide
+	locals []map[string]*Variable
+	// interactive is set while Run reads commands from the terminal
+	interactive bool
 }
 
-// NewShell creates and initializes a new Shell instance with autocomplete support
+// NewShell creates and initializes a new Shell instance with autocomplete
+// support. Its terminal is only set up when Run starts the REPL.
 func NewShell() *Shell {
 	paths := strings.Split(os.Getenv("PATH"), ":")
 	executables := make(map[string]struct{})
@@ -42,23 +67,16 @@ func NewShell() *Shell {
 	}
 	sort.Strings(allCommands)
 
+	cwd, _ := os.Getwd()
 	shell := &Shell{
 		allCommands: allCommands,
 		history:     []string{},
+		options:     map[string]bool{},
+		funcs:       map[string]*FuncDef{},
+		arg0:        os.Args[0],
+		cwd:         cwd,
 	}
-
-	rl, err := readline.NewEx(&readline.Config{
-		Prompt:          "$ ",
-		AutoComplete:    shell,
-		InterruptPrompt: "^C",
-		EOFPrompt:       "exit",
-		Listener:        &BellListener{},
-	})
-	if err != nil {
-		panic(err)
-	}
-
-	shell.rl = rl
+	shell.loadEnviron()
 
 	// Load history from HISTFILE if set
 	if histfile := os.Getenv("HISTFILE"); histfile != "" {
@@ -75,25 +93,152 @@ func NewShell() *Shell {
 	return shell
 }
 
+// subshell returns a copy of the shell for running a subshell, so that the
+// changes it makes to variables, functions, options and the working
+// directory stay inside it
+func (s *Shell) subshell() *Shell {
+	sub := *s
+	sub.inSubshell = true
+	sub.procSubs = nil
+	// break and continue do not reach the loops around a subshell
+	sub.loopDepth = 0
+	sub.options = maps.Clone(s.options)
+	sub.funcs = maps.Clone(s.funcs)
+	sub.locals = make([]map[string]*Variable, len(s.locals))
+	for i, scope := range s.locals {
+		sub.locals[i] = maps.Clone(scope)
+	}
+	sub.pipeStatus = slices.Clone(s.pipeStatus)
+	sub.vars = make(map[string]*Variable, len(s.vars))
+	for name, v := range s.vars {
+		sub.vars[name] = v.clone()
+	}
+	return &sub
+}
+
 // Run starts the shell's REPL (Read-Eval-Print Loop)
 func (s *Shell) Run() {
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:          "$ ",
+		AutoComplete:    s,
+		InterruptPrompt: "^C",
+		EOFPrompt:       "exit",
+		Listener:        &BellListener{},
+	})
+	if err != nil {
+		panic(err)
+	}
+	s.rl = rl
+	s.interactive = true
 	defer s.rl.Close()
 
 	for {
-		commandLine, err := s.rl.Readline()
+		commandLine, err := s.readCommand()
 		if err != nil {
 			fmt.Println("\x07")
 			return
 		}
+		if commandLine == "" {
+			continue
+		}
 
 		s.history = append(s.history, commandLine)
 		if err = s.executeCommand(commandLine); err != nil {
-			fmt.Println(err)
+			switch err.(type) {
+			case exitStatus, fatalError:
+			default:
+				fmt.Fprintln(os.Stderr, err)
+			}
 			continue
 		}
 	}
 }
 
+// readCommand reads a command, prompting with $PS2 for more lines while
+// the input is incomplete, such as inside quotes or a here-document
+func (s *Shell) readCommand() (string, error) {
+	commandLine, err := s.rl.Readline()
+	if err != nil {
+		return "", err
+	}
+	defer s.rl.SetPrompt("$ ")
+
+	for {
+		if _, err := parse(commandLine); !errors.Is(err, errIncomplete) {
+			return commandLine, nil
+		}
+		ps2, ok := s.getVar("PS2")
+		if !ok {
+			ps2 = "> "
+		}
+		s.rl.SetPrompt(ps2)
+
+		line, err := s.rl.Readline()
+		if err == readline.ErrInterrupt {
+			return "", nil
+		}
+		if err != nil {
+			// Running what was read reports the unexpected end of file
+			return commandLine, nil
+		}
+		commandLine += "\n" + line
+	}
+}
+
+// runScript runs the commands read from r, each one as soon as its last
+// line has been read, and returns the status of the last command. r is read
+// a byte at a time, so that when it is the standard input the commands can
+// read the lines that follow them. A syntax error ends the script with
+// status 2.
+func (s *Shell) runScript(r io.Reader) int {
+	var commandLine string
+	for {
+		line, readErr := readScriptLine(r)
+		commandLine += line
+		if readErr == nil {
+			if _, err := parse(commandLine); errors.Is(err, errIncomplete) {
+				continue
+			}
+		}
+
+		list, err := parse(commandLine)
+		if err != nil {
+			s.lastStatus = 2
+			fmt.Fprintf(os.Stderr, "%s: %s\n", s.arg0, err)
+			return s.lastStatus
+		}
+		// Blank lines and comments leave $? alone
+		if len(list.Items) > 0 {
+			switch err := s.executeCommand(commandLine).(type) {
+			case nil, exitStatus:
+			case fatalError:
+				return int(err)
+			default:
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+		commandLine = ""
+		if readErr != nil {
+			return s.lastStatus
+		}
+	}
+}
+
+// readScriptLine reads a line of a script, with its newline
+func readScriptLine(r io.Reader) (string, error) {
+	var line []byte
+	var b [1]byte
+	for {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return string(line), err
+		}
+		line = append(line, b[0])
+		if b[0] == '\n' {
+			return string(line), nil
+		}
+	}
+}
+
 // Do implements readline.AutoCompleter interface
 func (s *Shell) Do(line []rune, pos int) ([][]rune, int) {
 	lineStr := string(line[:pos])
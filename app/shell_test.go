@@ -1,6 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -46,3 +50,65 @@ func TestShell_Do(t *testing.T) {
 		})
 	}
 }
+
+func TestShell_runScript(t *testing.T) {
+	tests := map[string]struct {
+		script         string
+		params         []string
+		expected       string
+		expectedStatus int
+	}{
+		"happy path - commands run in order":              {script: "echo a\necho b\n", expected: "a\nb\n"},
+		"happy path - multi-line command":                 {script: "for x in 1 2; do\n  echo $x\ndone\n", expected: "1\n2\n"},
+		"happy path - positional parameters":              {script: "echo $# $2", params: []string{"a", "b"}, expected: "2 b\n"},
+		"happy path - status of the last command":         {script: "echo a\nfalse\n", expected: "a\n", expectedStatus: 1},
+		"happy path - read takes the next line":           {script: "read x\nhello\necho got $x\n", expected: "got hello\n"},
+		"edge case - shebang and comments keep $?":        {script: "#!/usr/bin/env gosh\nfalse\n# done\n\n", expectedStatus: 1},
+		"edge case - no trailing newline":                 {script: "echo a", expected: "a\n"},
+		"sad path - syntax error ends the script":         {script: "echo a\nfi\necho b\n", expected: "a\n", expectedStatus: 2},
+		"sad path - unterminated command":                 {script: "echo a\nif true; then\n", expected: "a\n", expectedStatus: 2},
+		"sad path - failing command does not stop":        {script: "false\necho b\n", expected: "b\n"},
+		"sad path - failed expansion ends the script":     {script: "echo a\necho ${unset_xyz:?oops}; echo b\necho c\n", expected: "a\n", expectedStatus: 1},
+		"sad path - failed assignment ends the script":    {script: "readonly ro_xyz=1\nro_xyz=2\necho b\n", expectedStatus: 1},
+		"edge case - failed expansion ends a subshell":    {script: "(echo ${unset_xyz:?oops}; echo a)\necho $?\n", expected: "1\n"},
+		"sad path - runaway recursion ends the script":    {script: "f() { echo $1; f $(( $1 + 1 )); echo back; }\nFUNCNEST=3 f 1\necho after\n", expected: "1\n2\n3\n", expectedStatus: 1},
+		"sad path - failed here-string ends the script":   {script: "cat <<< ${unset_xyz:?oops}\necho b\n", expectedStatus: 1},
+		"sad path - failed here-document ends the script": {script: "cat <<EOF\n${unset_xyz:?oops}\nEOF\necho b\n", expectedStatus: 1},
+		"edge case - failed redirection does not end it":  {script: "echo a < /nonexistent_xyz\necho b\n", expected: "b\n"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			shell.params = tc.params
+
+			// The script is the shell's stdin, so that read sees its lines
+			oldStdin, oldStdout, oldStderr := os.Stdin, os.Stdout, os.Stderr
+			rIn, wIn, _ := os.Pipe()
+			rOut, wOut, _ := os.Pipe()
+			devNull, _ := os.Open(os.DevNull)
+			os.Stdin, os.Stdout, os.Stderr = rIn, wOut, devNull
+			go func() {
+				io.Copy(wIn, strings.NewReader(tc.script))
+				wIn.Close()
+			}()
+
+			status := shell.runScript(rIn)
+
+			wOut.Close()
+			os.Stdin, os.Stdout, os.Stderr = oldStdin, oldStdout, oldStderr
+			rIn.Close()
+			devNull.Close()
+
+			var buf bytes.Buffer
+			io.Copy(&buf, rOut)
+
+			if buf.String() != tc.expected {
+				t.Errorf("expected output %q, got %q", tc.expected, buf.String())
+			}
+			if status != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, status)
+			}
+		})
+	}
+}
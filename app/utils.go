@@ -1,10 +1,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/chzyer/readline"
 )
@@ -13,6 +17,7 @@ const (
 	SingleQuote = '\''
 	DoubleQuote = '"'
 	Backslash   = '\\'
+	Backquote   = '`'
 
 	// FilePermission is 0o644 (rw-r--r--): owner can read/write, others can read
 	FilePermission = 0o644
@@ -32,24 +37,65 @@ func (l *BellListener) OnChange(line []rune, pos int, key rune) ([]rune, int, bo
 }
 
 func (s *Shell) isInPath(command string) string {
-	paths := strings.Split(os.Getenv("PATH"), ":")
+	if command == "" {
+		return ""
+	}
+	if strings.Contains(command, "/") {
+		info, err := os.Stat(s.resolvePath(command))
+		if err == nil && !info.IsDir() && info.Mode()&ExecPermission != 0 {
+			return command
+		}
+		return ""
+	}
+
+	path, _ := s.getVar("PATH")
+	paths := strings.Split(path, ":")
 	for _, path := range paths {
 		file := filepath.Join(path, command)
 		info, err := os.Stat(file)
-		if err == nil && info.Mode()&ExecPermission != 0 {
+		if err == nil && !info.IsDir() && info.Mode()&ExecPermission != 0 {
 			return file
 		}
 	}
 	return ""
 }
 
-func (s *Shell) writeToFile(path string, data []byte, append bool) {
-	if append {
-		if f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, FilePermission); err == nil {
-			_, _ = f.Write(data)
-			_ = f.Close()
+// resolvePath returns the file a path names, taking a relative path from
+// the shell's working directory rather than the process's
+func (s *Shell) resolvePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(s.cwd, path)
+}
+
+// strerror returns the cause of a failed system call in the words of
+// strerror, such as "No such file or directory"
+func strerror(err error) string {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	msg := err.Error()
+	return strings.ToUpper(msg[:1]) + msg[1:]
+}
+
+// commandStatus returns the exit status of an external command given the
+// error from running it. A command that could not be started has status
+// 127 if it does not exist and 126 otherwise, along with the reason.
+func commandStatus(err error) (int, string) {
+	if err == nil {
+		return 0, ""
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return 128 + int(ws.Signal()), ""
 		}
-	} else {
-		_ = os.WriteFile(path, data, FilePermission)
+		return exitErr.ExitCode(), ""
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return 127, strerror(err)
 	}
+	return 126, strerror(err)
 }
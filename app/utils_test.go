@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 )
@@ -24,6 +26,10 @@ func TestShell_isInPath(t *testing.T) {
 			command:     "nonexistent_command_xyz_123",
 			expectFound: false,
 		},
+		"edge case - empty name": {
+			command:     "",
+			expectFound: false,
+		},
 		"happy path - echo command exists": {
 			command:     "echo",
 			expectFound: true,
@@ -45,3 +51,33 @@ func TestShell_isInPath(t *testing.T) {
 		})
 	}
 }
+
+func TestCommandStatus(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "fails"), []byte("#!/bin/sh\nexit 3\n"), 0755)
+	os.WriteFile(filepath.Join(dir, "noexec"), []byte("#!/bin/sh\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "badelf"), []byte("\x7fELFjunk"), 0755)
+
+	tests := map[string]struct {
+		file           string
+		expectedStatus int
+		expectedReason string
+	}{
+		"happy path - exit status":          {file: "fails", expectedStatus: 3},
+		"sad path - missing file":           {file: "missing", expectedStatus: 127, expectedReason: "No such file or directory"},
+		"sad path - not executable":         {file: "noexec", expectedStatus: 126, expectedReason: "Permission denied"},
+		"sad path - not an executable file": {file: "badelf", expectedStatus: 126, expectedReason: "Exec format error"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			status, reason := commandStatus(exec.Command(filepath.Join(dir, tc.file)).Run())
+			if status != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, status)
+			}
+			if reason != tc.expectedReason {
+				t.Errorf("expected reason %q, got %q", tc.expectedReason, reason)
+			}
+		})
+	}
+}
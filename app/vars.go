@@ -0,0 +1,589 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Variable is a shell variable. Variables imported from the environment at
+// startup are marked Exported. Indexed arrays keep their elements in Array
+// and associative arrays in Assoc; a plain variable uses Value.
+type Variable struct {
+	Value    string
+	Array    map[int]string
+	Assoc    map[string]string
+	Exported bool
+	ReadOnly bool
+	Integer  bool
+}
+
+// clone returns a copy of the variable that shares none of its elements,
+// for a subshell to change
+func (v *Variable) clone() *Variable {
+	c := *v
+	c.Array = maps.Clone(v.Array)
+	c.Assoc = maps.Clone(v.Assoc)
+	return &c
+}
+
+// scalar returns the value of the variable when it is used without a
+// subscript, which for arrays is element 0
+func (v *Variable) scalar() (string, bool) {
+	switch {
+	case v.Array != nil:
+		value, ok := v.Array[0]
+		return value, ok
+	case v.Assoc != nil:
+		value, ok := v.Assoc["0"]
+		return value, ok
+	}
+	return v.Value, true
+}
+
+// keys returns the subscripts of the variable's elements in order
+func (v *Variable) keys() []string {
+	switch {
+	case v.Array != nil:
+		indexes := make([]int, 0, len(v.Array))
+		for i := range v.Array {
+			indexes = append(indexes, i)
+		}
+		sort.Ints(indexes)
+		keys := make([]string, len(indexes))
+		for i, index := range indexes {
+			keys[i] = strconv.Itoa(index)
+		}
+		return keys
+	case v.Assoc != nil:
+		keys := make([]string, 0, len(v.Assoc))
+		for key := range v.Assoc {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	return []string{"0"}
+}
+
+// values returns the variable's elements ordered by subscript
+func (v *Variable) values() []string {
+	keys := v.keys()
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		switch {
+		case v.Array != nil:
+			index, _ := strconv.Atoi(key)
+			values[i] = v.Array[index]
+		case v.Assoc != nil:
+			values[i] = v.Assoc[key]
+		default:
+			values[i] = v.Value
+		}
+	}
+	return values
+}
+
+// loadEnviron creates a shell variable for each environment variable
+func (s *Shell) loadEnviron() {
+	s.vars = make(map[string]*Variable)
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			s.vars[name] = &Variable{Value: value, Exported: true}
+		}
+	}
+}
+
+// environ returns the environment for a child process: every exported
+// variable, followed by the NAME=value entries in extra
+func (s *Shell) environ(extra []string) []string {
+	names := make([]string, 0, len(s.vars))
+	for name, v := range s.vars {
+		if v.Exported && v.Array == nil && v.Assoc == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	env := make([]string, 0, len(names)+len(extra))
+	for _, name := range names {
+		env = append(env, name+"="+s.vars[name].Value)
+	}
+	return append(env, extra...)
+}
+
+func (s *Shell) getVar(name string) (string, bool) {
+	if v, ok := s.vars[name]; ok {
+		return v.scalar()
+	}
+	return "", false
+}
+
+// setVar assigns a value to a variable, creating it if needed. Integer
+// variables store the evaluated value and arrays store it in element 0.
+func (s *Shell) setVar(name, value string) error {
+	v, ok := s.vars[name]
+	if !ok {
+		s.vars[name] = &Variable{Value: value}
+		return nil
+	}
+	if v.ReadOnly {
+		return fmt.Errorf("%s: readonly variable", name)
+	}
+	if v.Integer {
+		n, err := s.evalArith(value)
+		if err != nil {
+			return err
+		}
+		value = strconv.Itoa(n)
+	}
+
+	switch {
+	case v.Array != nil:
+		v.Array[0] = value
+	case v.Assoc != nil:
+		v.Assoc["0"] = value
+	default:
+		v.Value = value
+	}
+	return nil
+}
+
+// setElement assigns one element of an array, turning a plain variable into
+// an indexed array
+func (s *Shell) setElement(name, index, value string) error {
+	v, ok := s.vars[name]
+	if !ok {
+		v = &Variable{}
+		s.vars[name] = v
+	}
+	if v.ReadOnly {
+		return fmt.Errorf("%s: readonly variable", name)
+	}
+	if v.Integer {
+		n, err := s.evalArith(value)
+		if err != nil {
+			return err
+		}
+		value = strconv.Itoa(n)
+	}
+
+	if v.Assoc != nil {
+		key, err := s.expandWord(index)
+		if err != nil {
+			return err
+		}
+		v.Assoc[key] = value
+		return nil
+	}
+
+	i, err := s.arrayIndex(v, index)
+	if err != nil {
+		return err
+	}
+	if v.Array == nil {
+		v.Array = map[int]string{}
+		if ok {
+			v.Array[0] = v.Value
+		}
+		v.Value = ""
+	}
+	v.Array[i] = value
+	return nil
+}
+
+// arrayIndex evaluates the subscript of an indexed array; negative indexes
+// count back from the end of the array
+func (s *Shell) arrayIndex(v *Variable, index string) (int, error) {
+	i, err := s.evalArith(index)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		last := -1
+		for key := range v.Array {
+			last = max(last, key)
+		}
+		if i += last + 1; i < 0 {
+			return 0, fmt.Errorf("%s: bad array subscript", index)
+		}
+	}
+	return i, nil
+}
+
+// element returns the value of name[index]
+func (s *Shell) element(name, index string) (string, bool) {
+	v, ok := s.vars[name]
+	if !ok {
+		return "", false
+	}
+
+	switch {
+	case v.Assoc != nil:
+		key, err := s.expandWord(index)
+		if err != nil {
+			return "", false
+		}
+		value, ok := v.Assoc[key]
+		return value, ok
+	case v.Array != nil:
+		i, err := s.arrayIndex(v, index)
+		if err != nil {
+			return "", false
+		}
+		value, ok := v.Array[i]
+		return value, ok
+	}
+	if i, err := s.evalArith(index); err == nil && i == 0 {
+		return v.Value, true
+	}
+	return "", false
+}
+
+// unsetVar removes a variable, or a single element for name[index]
+func (s *Shell) unsetVar(param string) error {
+	name, index := splitSubscript(param)
+	v, ok := s.vars[name]
+	if !ok {
+		return nil
+	}
+	if v.ReadOnly {
+		return fmt.Errorf("%s: cannot unset: readonly variable", name)
+	}
+
+	switch {
+	case index == "" || index == "@" || index == "*":
+		delete(s.vars, name)
+	case v.Assoc != nil:
+		key, err := s.expandWord(index)
+		if err != nil {
+			return err
+		}
+		delete(v.Assoc, key)
+	case v.Array != nil:
+		i, err := s.arrayIndex(v, index)
+		if err != nil {
+			return err
+		}
+		delete(v.Array, i)
+	default:
+		delete(s.vars, name)
+	}
+	return nil
+}
+
+// withTempVars sets each NAME=value entry of env as an exported variable
+// and returns a function that restores the variables it replaced
+func (s *Shell) withTempVars(env []string) func() {
+	saved := map[string]*Variable{}
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		if _, ok := saved[name]; !ok {
+			saved[name] = s.vars[name]
+		}
+		s.vars[name] = &Variable{Value: value, Exported: true}
+	}
+
+	return func() { s.restoreVars(saved) }
+}
+
+// restoreVars puts back the variables in saved, deleting those saved as nil
+func (s *Shell) restoreVars(saved map[string]*Variable) {
+	for name, v := range saved {
+		if v == nil {
+			delete(s.vars, name)
+		} else {
+			s.vars[name] = v
+		}
+	}
+}
+
+// makeLocal makes the variable named by a declare argument local to the
+// function being run, hiding any variable of that name until the function
+// returns. A readonly variable cannot be hidden.
+func (s *Shell) makeLocal(arg string) {
+	lhs, _, _ := strings.Cut(arg, "=")
+	name, _ := splitSubscript(strings.TrimSuffix(lhs, "+"))
+	scope := s.locals[len(s.locals)-1]
+	if _, ok := scope[name]; ok || !isName(name) {
+		return
+	}
+	if v, ok := s.vars[name]; ok && v.ReadOnly {
+		return
+	}
+	scope[name] = s.vars[name]
+	delete(s.vars, name)
+}
+
+// prefixEnv expands the assignments written before a command name into the
+// NAME=value entries added to that command's environment
+func (s *Shell) prefixEnv(assigns []string) ([]string, error) {
+	env := make([]string, 0, len(assigns))
+	for _, word := range assigns {
+		name, raw, _ := strings.Cut(word, "=")
+		if v, ok := s.vars[name]; ok && v.ReadOnly {
+			return nil, fmt.Errorf("%s: readonly variable", name)
+		}
+		value, err := s.expandAssignment(raw)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, name+"="+value)
+	}
+	return env, nil
+}
+
+// declare applies the attributes in set and clear (letters out of "aAirx")
+// to the variable named by arg, assigning it when arg is an assignment
+func (s *Shell) declare(arg, set, clear string) error {
+	lhs, _, hasValue := strings.Cut(arg, "=")
+	name, _ := splitSubscript(strings.TrimSuffix(lhs, "+"))
+	if !isName(name) || (hasValue && !isAssignment(arg)) {
+		return fmt.Errorf("`%s': not a valid identifier", arg)
+	}
+
+	v, ok := s.vars[name]
+	if !ok {
+		v = &Variable{}
+		s.vars[name] = v
+	}
+	if v.ReadOnly && (hasValue || strings.ContainsAny(clear, "r")) {
+		return fmt.Errorf("%s: readonly variable", name)
+	}
+	if strings.ContainsAny(clear, "aA") {
+		return fmt.Errorf("%s: cannot destroy array variables in this way", name)
+	}
+
+	for _, attr := range set {
+		switch attr {
+		case 'a':
+			if v.Assoc != nil {
+				return fmt.Errorf("%s: cannot convert associative to indexed array", name)
+			}
+			if v.Array == nil {
+				v.Array = map[int]string{}
+				if ok && v.Value != "" {
+					v.Array[0] = v.Value
+				}
+				v.Value = ""
+			}
+		case 'A':
+			if v.Array != nil {
+				return fmt.Errorf("%s: cannot convert indexed to associative array", name)
+			}
+			if v.Assoc == nil {
+				v.Assoc = map[string]string{}
+				if ok && v.Value != "" {
+					v.Assoc["0"] = v.Value
+				}
+				v.Value = ""
+			}
+		case 'i':
+			v.Integer = true
+		case 'x':
+			v.Exported = true
+		}
+	}
+	for _, attr := range clear {
+		switch attr {
+		case 'i':
+			v.Integer = false
+		case 'x':
+			v.Exported = false
+		}
+	}
+
+	if hasValue {
+		if err := s.assign(arg); err != nil {
+			return err
+		}
+	}
+	if strings.ContainsRune(set, 'r') {
+		v.ReadOnly = true
+	}
+	return nil
+}
+
+// declareLine formats a variable the way "declare -p" prints it
+func declareLine(name string, v *Variable) string {
+	flags := ""
+	for _, attr := range []struct {
+		flag byte
+		on   bool
+	}{
+		{'a', v.Array != nil}, {'A', v.Assoc != nil}, {'i', v.Integer},
+		{'r', v.ReadOnly}, {'x', v.Exported},
+	} {
+		if attr.on {
+			flags += string(attr.flag)
+		}
+	}
+	if flags == "" {
+		flags = "-"
+	}
+
+	if v.Array == nil && v.Assoc == nil {
+		return fmt.Sprintf("declare -%s %s=%s", flags, name, quoteValue(v.Value))
+	}
+	keys, values := v.keys(), v.values()
+	elems := make([]string, len(keys))
+	for i, key := range keys {
+		elems[i] = fmt.Sprintf("[%s]=%s", key, quoteValue(values[i]))
+	}
+	return fmt.Sprintf("declare -%s %s=(%s)", flags, name, strings.Join(elems, " "))
+}
+
+// quoteValue double-quotes a value so that it reads back unchanged
+func quoteValue(value string) string {
+	var b strings.Builder
+	b.WriteByte(DoubleQuote)
+	for i := 0; i < len(value); i++ {
+		if strings.IndexByte("\\\"$`", value[i]) >= 0 {
+			b.WriteByte(Backslash)
+		}
+		b.WriteByte(value[i])
+	}
+	b.WriteByte(DoubleQuote)
+	return b.String()
+}
+
+// assign performs an assignment word: NAME=value, NAME+=value,
+// NAME[index]=value or the compound array form NAME=(value...)
+func (s *Shell) assign(word string) error {
+	lhs, raw, _ := strings.Cut(word, "=")
+	appendMode := strings.HasSuffix(lhs, "+")
+	name, index := splitSubscript(strings.TrimSuffix(lhs, "+"))
+
+	if index == "" && strings.HasPrefix(raw, "(") && strings.HasSuffix(raw, ")") {
+		return s.assignArray(name, raw[1:len(raw)-1], appendMode)
+	}
+
+	value, err := s.expandAssignment(raw)
+	if err != nil {
+		return err
+	}
+
+	if appendMode {
+		var old string
+		if index != "" {
+			old, _ = s.element(name, index)
+		} else {
+			old, _ = s.getVar(name)
+		}
+		if v, ok := s.vars[name]; ok && v.Integer {
+			a, errA := s.evalArith(old)
+			b, errB := s.evalArith(value)
+			if err := errors.Join(errA, errB); err != nil {
+				return err
+			}
+			value = strconv.Itoa(a + b)
+		} else {
+			value = old + value
+		}
+	}
+
+	if index != "" {
+		return s.setElement(name, index, value)
+	}
+	return s.setVar(name, value)
+}
+
+// assignArray assigns the words of a compound assignment to an array.
+// Words of the form [key]=value set that key to a single word; other words
+// are expanded like command arguments and their fields take the next
+// indexes. Associative arrays require keys.
+func (s *Shell) assignArray(name, body string, appendMode bool) error {
+	v, ok := s.vars[name]
+	if ok && v.ReadOnly {
+		return fmt.Errorf("%s: readonly variable", name)
+	}
+	if !ok {
+		v = &Variable{}
+		s.vars[name] = v
+	}
+
+	next := 0
+	switch {
+	case v.Assoc != nil:
+		if !appendMode {
+			v.Assoc = map[string]string{}
+		}
+	case appendMode && v.Array != nil:
+		for key := range v.Array {
+			next = max(next, key+1)
+		}
+	case appendMode:
+		v.Array = map[int]string{0: v.Value}
+		next = 1
+	default:
+		v.Array = map[int]string{}
+	}
+	v.Value = ""
+
+	for _, word := range lexWords(body) {
+		if strings.HasPrefix(word, "[") {
+			if end := strings.Index(word, "]="); end > 0 {
+				value, err := s.expandWord(word[end+2:])
+				if err != nil {
+					return err
+				}
+				if err := s.setElement(name, word[1:end], value); err != nil {
+					return err
+				}
+				if v.Array != nil {
+					index, _ := s.arrayIndex(v, word[1:end])
+					next = index + 1
+				}
+				continue
+			}
+		}
+		if v.Assoc != nil {
+			return fmt.Errorf("%s: %s: must use subscript when assigning associative array", name, word)
+		}
+		fields, err := s.expandWords([]string{word})
+		if err != nil {
+			return err
+		}
+		for _, field := range fields {
+			v.Array[next] = field
+			next++
+		}
+	}
+	return nil
+}
+
+// splitSubscript splits name[index] into its name and index
+func splitSubscript(param string) (name, index string) {
+	if open := strings.IndexByte(param, '['); open > 0 && strings.HasSuffix(param, "]") {
+		return param[:open], param[open+1 : len(param)-1]
+	}
+	return param, ""
+}
+
+// isAssignment reports whether a raw word is an assignment such as
+// NAME=value, NAME+=value or NAME[index]=value
+func isAssignment(word string) bool {
+	lhs, _, ok := strings.Cut(word, "=")
+	if !ok {
+		return false
+	}
+	name, _ := splitSubscript(strings.TrimSuffix(lhs, "+"))
+	return isName(name)
+}
+
+// isName reports whether s is a valid variable name
+func isName(s string) bool {
+	if s == "" || isDigit(s[0]) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isNameChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
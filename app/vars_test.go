@@ -0,0 +1,203 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsAssignment(t *testing.T) {
+	tests := map[string]struct {
+		word     string
+		expected bool
+	}{
+		"happy path - simple assignment":    {word: "FOO=bar", expected: true},
+		"happy path - empty value":          {word: "FOO=", expected: true},
+		"happy path - quoted value":         {word: `FOO="a b"`, expected: true},
+		"happy path - underscore and digit": {word: "_x1=2", expected: true},
+		"sad path - no equals":              {word: "FOO", expected: false},
+		"sad path - leading digit":          {word: "1x=2", expected: false},
+		"sad path - quoted name":            {word: `"FOO"=bar`, expected: false},
+		"sad path - empty name":             {word: "=bar", expected: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if result := isAssignment(tc.word); result != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestShell_assign(t *testing.T) {
+	shell := NewShell()
+	shell.setVar("NAME", "world")
+
+	tests := map[string]struct {
+		word     string
+		expected string
+	}{
+		"happy path - literal value":     {word: "GREETING=hello", expected: "hello"},
+		"happy path - expanded value":    {word: "GREETING=$NAME", expected: "world"},
+		"happy path - double quotes":     {word: `GREETING="hello $NAME"`, expected: "hello world"},
+		"happy path - single quotes":     {word: `GREETING='hello $NAME'`, expected: "hello $NAME"},
+		"happy path - value with equals": {word: "GREETING=a=b", expected: "a=b"},
+		"edge case - empty value":        {word: "GREETING=", expected: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell.assign(tc.word)
+			result, ok := shell.getVar("GREETING")
+			if !ok {
+				t.Fatal("expected GREETING to be set")
+			}
+			if result != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestShell_setVar_NotExported(t *testing.T) {
+	shell := NewShell()
+	shell.setVar("GOSH_TEST_VAR", "value")
+
+	if shell.vars["GOSH_TEST_VAR"].Exported {
+		t.Error("expected new variable not to be exported")
+	}
+	if shell.vars["HOME"] != nil && !shell.vars["HOME"].Exported {
+		t.Error("expected HOME from the environment to be exported")
+	}
+}
+
+func TestShell_assign_Arrays(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"a.go", "b.go"} {
+		os.WriteFile(filepath.Join(dir, file), nil, 0o644)
+	}
+
+	tests := map[string]struct {
+		words    []string
+		param    string
+		expected string
+	}{
+		"happy path - indexed array":       {words: []string{"a=(x y z)"}, param: "a[1]", expected: "y"},
+		"happy path - element assignment":  {words: []string{"a[3]=w"}, param: "a[3]", expected: "w"},
+		"happy path - explicit index":      {words: []string{"a=([2]=two)"}, param: "a[2]", expected: "two"},
+		"happy path - append to array":     {words: []string{"a=(x)", "a+=(y)"}, param: "a[1]", expected: "y"},
+		"happy path - append to scalar":    {words: []string{"s=ab", "s+=cd"}, param: "s", expected: "abcd"},
+		"happy path - negative index":      {words: []string{"a=(x y z)"}, param: "a[-1]", expected: "z"},
+		"happy path - quoted element":      {words: []string{`a=("x y" z)`}, param: "a[0]", expected: "x y"},
+		"edge case - scalar is element 0":  {words: []string{"a=(x y)"}, param: "a", expected: "x"},
+		"edge case - unset element empty":  {words: []string{"a=(x)"}, param: "a[5]", expected: ""},
+		"happy path - glob elements":       {words: []string{"a=(" + dir + "/*.go)"}, param: "a[1]", expected: dir + "/b.go"},
+		"happy path - substitution split":  {words: []string{"a=($(echo x y z))"}, param: "a[2]", expected: "z"},
+		"happy path - brace elements":      {words: []string{"a=({1..3})"}, param: "a[2]", expected: "3"},
+		"happy path - variable split":      {words: []string{"x='p q'", "a=($x)"}, param: "a[1]", expected: "q"},
+		"edge case - keyed value one word": {words: []string{"x='p q'", "a=([1]=$x)"}, param: "a[1]", expected: "p q"},
+		"edge case - quoted not split":     {words: []string{"x='p q'", `a=("$x")`}, param: "a[0]", expected: "p q"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			for _, word := range tc.words {
+				if err := shell.assign(word); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if result, _ := shell.paramValue(tc.param); result != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestShell_declare(t *testing.T) {
+	tests := map[string]struct {
+		arg           string
+		set, clear    string
+		expectedLine  string
+		expectedError bool
+	}{
+		"happy path - export with value": {
+			arg: "V=1", set: "x",
+			expectedLine: `declare -x V="1"`,
+		},
+		"happy path - integer": {
+			arg: "V=7", set: "i",
+			expectedLine: `declare -i V="7"`,
+		},
+		"happy path - readonly array": {
+			arg: "V=(a b)", set: "ar",
+			expectedLine: `declare -ar V=([0]="a" [1]="b")`,
+		},
+		"happy path - associative array": {
+			arg: "V=([k]=v)", set: "A",
+			expectedLine: `declare -A V=([k]="v")`,
+		},
+		"happy path - no attributes": {
+			arg:          `V='a"b'`,
+			expectedLine: `declare -- V="a\"b"`,
+		},
+		"sad path - invalid name": {
+			arg: "1V=1", set: "x",
+			expectedError: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			shell := NewShell()
+			err := shell.declare(tc.arg, tc.set, tc.clear)
+			if tc.expectedError {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result := declareLine("V", shell.vars["V"]); result != tc.expectedLine {
+				t.Errorf("expected %q, got %q", tc.expectedLine, result)
+			}
+		})
+	}
+}
+
+func TestShell_setVar_ReadOnly(t *testing.T) {
+	shell := NewShell()
+	shell.declare("RO=1", "r", "")
+
+	if err := shell.setVar("RO", "2"); err == nil {
+		t.Error("expected an error assigning a readonly variable")
+	}
+	if err := shell.unsetVar("RO"); err == nil {
+		t.Error("expected an error unsetting a readonly variable")
+	}
+	if value, _ := shell.getVar("RO"); value != "1" {
+		t.Errorf("expected RO to stay 1, got %q", value)
+	}
+}
+
+func TestShell_environ(t *testing.T) {
+	shell := NewShell()
+	shell.vars = map[string]*Variable{}
+	shell.setVar("LOCAL", "1")
+	shell.declare("B=2", "x", "")
+	shell.declare("A=1", "x", "")
+
+	result := shell.environ([]string{"EXTRA=3"})
+	expected := []string{"A=1", "B=2", "EXTRA=3"}
+	if len(result) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, result)
+	}
+	for i := range expected {
+		if result[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, result)
+		}
+	}
+}